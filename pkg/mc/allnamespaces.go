@@ -0,0 +1,99 @@
+package mc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+)
+
+var (
+	// getFlagsWithValue are the flags of kubectl get that take a separate value, which isn't a positional arg
+	getFlagsWithValue = map[string]bool{
+		"-l": true, "--selector": true, "--field-selector": true, "-o": true, "--output": true, "-L": true,
+		"--label-columns": true, "--sort-by": true, "--template": true, "--chunk-size": true, "--request-timeout": true,
+		"--as": true, "--as-group": true, "--cluster": true, "--user": true,
+	}
+	// getFlagsSelectingObjects are the flags of kubectl get that select objects in another way than by type
+	getFlagsSelectingObjects = []string{"-f", "--filename", "-k", "--kustomize", "-n", "--namespace", "-A", "--all-namespaces", "--raw"}
+)
+
+// listAllNamespaces returns true if the results of a multi-namespace get can be retrieved with a single
+// all-namespaces list per context, which is the case for json output of a get by type without object names.
+// An empty namespace is the current namespace of every context, which the items can't be split into
+func (mc *MC) listAllNamespaces(args []string) bool {
+	namespaces := strings.Split(mc.Namespaces, ",")
+	if mc.PerNamespace || mc.Template || len(namespaces) < 2 || contains(namespaces, "") {
+		return false
+	}
+	if mc.Output == "" || (isTabular(mc.Output) && mc.Columns == "") || kubectlVerb(args) != "get" {
+		return false
+	}
+
+	var positional []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		for _, f := range getFlagsSelectingObjects {
+			if arg == f || strings.HasPrefix(arg, f+"=") || (len(f) == 2 && strings.HasPrefix(arg, f) && f != arg && !strings.HasPrefix(arg, "--")) {
+				return false
+			}
+		}
+		switch {
+		case getFlagsWithValue[arg]:
+			i++
+		case !strings.HasPrefix(arg, "-"):
+			positional = append(positional, arg)
+		}
+	}
+	// the first positional arg is the verb, the second one the type. Anything else are object names
+	return len(positional) == 2 && !strings.Contains(positional[1], "/")
+}
+
+// runAllNamespaces executes args with --all-namespaces once per context and splits the items of every result into
+// one result per requested namespace, identical to the results of executing args in every namespace
+func (mc *MC) runAllNamespaces(ctx context.Context, contexts []string, args []string) ([]Result, error) {
+	r := mc.runner(nil)
+	r.opts.Namespaces = []string{""}
+	results, err := r.RunContexts(ctx, contexts, append(append([]string{}, args...), "--all-namespaces"))
+
+	namespaces := strings.Split(mc.Namespaces, ",")
+	var split []Result
+	for _, res := range results {
+		split = append(split, splitNamespaces(res, namespaces)...)
+	}
+	sortResults(split)
+	return split, err
+}
+
+// splitNamespaces returns a result for every namespace, with the items of the json list of res that are in this
+// namespace. Cluster-scoped items don't have a namespace and are kept in every namespace, like kubectl returns them
+// for every namespace. A failed result is returned for every namespace as is
+func splitNamespaces(res Result, namespaces []string) (split []Result) {
+	var list map[string]interface{}
+	items, isList := []interface{}{}, false
+	// numbers are decoded as json.Number, so large integers are re-marshalled without losing precision
+	d := json.NewDecoder(bytes.NewReader(res.Stdout))
+	d.UseNumber()
+	if res.Err == nil && d.Decode(&list) == nil {
+		items, isList = list["items"].([]interface{})
+	}
+
+	for _, ns := range namespaces {
+		r := res
+		r.Namespace = ns
+		if isList {
+			filtered := []interface{}{}
+			for _, item := range items {
+				if itemNamespace, _ := jsonPathString(item, ".metadata.namespace"); itemNamespace == ns || itemNamespace == "" {
+					filtered = append(filtered, item)
+				}
+			}
+			list["items"] = filtered
+			if b, err := json.Marshal(list); err == nil {
+				r.Stdout = b
+			}
+		}
+		split = append(split, r)
+	}
+	return
+}
@@ -0,0 +1,107 @@
+package mc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jonnylangefeld/kubectl-mc/pkg/mc/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMC_ListAllNamespaces(t *testing.T) {
+	json := &MC{Namespaces: "a,b", Output: JSON}
+	tests := map[string]struct {
+		mc   *MC
+		args []string
+		want bool
+	}{
+		"get by type":           {mc: json, args: []string{"get", "pods", "-o", "json"}, want: true},
+		"selectors":             {mc: json, args: []string{"get", "pods,svc", "-l", "app=x", "--field-selector", "status.phase=Running", "-o", "json"}, want: true},
+		"custom columns":        {mc: &MC{Namespaces: "a,b", Output: CSV, Columns: "NAME:.metadata.name"}, args: []string{"get", "pods", "-o", "json"}, want: true},
+		"empty namespace":       {mc: &MC{Namespaces: "kube-system,", Output: JSON}, args: []string{"get", "pods", "-o", "json"}},
+		"single namespace":      {mc: &MC{Namespaces: "a", Output: JSON}, args: []string{"get", "pods", "-o", "json"}},
+		"per namespace":         {mc: &MC{Namespaces: "a,b", Output: JSON, PerNamespace: true}, args: []string{"get", "pods", "-o", "json"}},
+		"template":              {mc: &MC{Namespaces: "a,b", Output: JSON, Template: true}, args: []string{"get", "pods", "-o", "json"}},
+		"text output":           {mc: &MC{Namespaces: "a,b"}, args: []string{"get", "pods"}},
+		"table parsed csv":      {mc: &MC{Namespaces: "a,b", Output: CSV}, args: []string{"get", "pods"}},
+		"other verb":            {mc: json, args: []string{"describe", "pods", "-o", "json"}},
+		"object name":           {mc: json, args: []string{"get", "pods", "pod-a", "-o", "json"}},
+		"type and name":         {mc: json, args: []string{"get", "pod/pod-a", "-o", "json"}},
+		"file":                  {mc: json, args: []string{"get", "-f", "pod.yaml", "-o", "json"}},
+		"explicit namespace":    {mc: json, args: []string{"get", "pods", "-nkube-system", "-o", "json"}},
+		"explicit all":          {mc: json, args: []string{"get", "pods", "--all-namespaces", "-o", "json"}},
+		"raw":                   {mc: json, args: []string{"get", "--raw=/api", "-o", "json"}},
+		"without a type at all": {mc: json, args: []string{"get", "-o", "json"}},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.want, test.mc.listAllNamespaces(test.args))
+		})
+	}
+}
+
+func TestSplitNamespaces(t *testing.T) {
+	res := Result{Context: kubeContext, Stdout: []byte(`{"apiVersion":"v1","items":[{"metadata":{"name":"x","namespace":"a"}},{"metadata":{"name":"y","namespace":"c"}}],"kind":"List"}`)}
+	assert.Equal(t, []Result{
+		{Context: kubeContext, Namespace: "a", Stdout: []byte(`{"apiVersion":"v1","items":[{"metadata":{"name":"x","namespace":"a"}}],"kind":"List"}`)},
+		{Context: kubeContext, Namespace: "b", Stdout: []byte(`{"apiVersion":"v1","items":[],"kind":"List"}`)},
+	}, splitNamespaces(res, []string{"a", "b"}))
+
+	clusterScoped := Result{Context: kubeContext, Stdout: []byte(`{"apiVersion":"v1","items":[{"metadata":{"name":"node-1"},"status":{"capacity":{"ephemeral-storage":9007199254740993}}}],"kind":"List"}`)}
+	assert.Equal(t, []Result{
+		{Context: kubeContext, Namespace: "a", Stdout: []byte(`{"apiVersion":"v1","items":[{"metadata":{"name":"node-1"},"status":{"capacity":{"ephemeral-storage":9007199254740993}}}],"kind":"List"}`)},
+		{Context: kubeContext, Namespace: "b", Stdout: []byte(`{"apiVersion":"v1","items":[{"metadata":{"name":"node-1"},"status":{"capacity":{"ephemeral-storage":9007199254740993}}}],"kind":"List"}`)},
+	}, splitNamespaces(clusterScoped, []string{"a", "b"}))
+
+	failed := Result{Context: kubeContext, Err: fmt.Errorf("forbidden"), ExitCode: 1}
+	assert.Equal(t, []Result{
+		{Context: kubeContext, Namespace: "a", Err: fmt.Errorf("forbidden"), ExitCode: 1},
+		{Context: kubeContext, Namespace: "b", Err: fmt.Errorf("forbidden"), ExitCode: 1},
+	}, splitNamespaces(failed, []string{"a", "b"}))
+}
+
+func TestMC_AllNamespacesOutputIsIdentical(t *testing.T) {
+	list := func(namespaces ...string) []byte {
+		var items []string
+		for _, ns := range namespaces {
+			items = append(items, fmt.Sprintf(`{"kind":"Pod","metadata":{"name":"pod-%s","namespace":%q}}`, ns, ns))
+		}
+		return []byte(`{"apiVersion":"v1","items":[` + strings.Join(items, ",") + `],"kind":"List","metadata":{"resourceVersion":""}}`)
+	}
+
+	execute := func(args []string, kubectlCalls int) string {
+		ctrl := gomock.NewController(t)
+		m := mocks.NewMockCmd(ctrl)
+		m.EXPECT().Output().Return([]byte("kind-kind\nkind-kind1\n"), nil)
+		mc := New("")
+		mc.getListContextsCmd = func() Cmd {
+			return m
+		}
+		calls := 0
+		mc.getKubectlCmd = func(ctx context.Context, args []string, c string, namespace string) Cmd {
+			calls++
+			k := mocks.NewMockCmd(ctrl)
+			if namespace == "" {
+				assert.Equal(t, "--all-namespaces", args[len(args)-1])
+				k.EXPECT().Output().Return(list("a", "b", "kube-system"), nil)
+			} else {
+				k.EXPECT().Output().Return(list(namespace), nil)
+			}
+			return k
+		}
+		b := bytes.NewBuffer([]byte(``))
+		mc.Cmd.SetOut(b)
+		mc.Cmd.SetArgs(append([]string{"-p", "1", "-n", "a,b", "-o", "yaml"}, args...))
+		assert.NoError(t, mc.Cmd.Execute())
+		assert.Equal(t, kubectlCalls, calls)
+		return b.String()
+	}
+
+	perNamespace := execute([]string{"--per-namespace", "--", "get", "pods"}, 4)
+	assert.Equal(t, perNamespace, execute([]string{"--", "get", "pods"}, 2))
+	assert.Contains(t, perNamespace, "'kind-kind1: b':\n  apiVersion: v1\n  items:\n  - kind: Pod\n    metadata:\n      name: pod-b\n      namespace: b\n")
+}
@@ -0,0 +1,248 @@
+package mc
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"go.uber.org/zap"
+)
+
+const auditLogFile = "history.jsonl"
+
+var errUnknownHistoryEntry = fmt.Errorf("there is no history entry with this id. Run `mc history` to list all entries")
+
+// auditEntry is a single invocation of mc in the audit log
+type auditEntry struct {
+	Timestamp time.Time `json:"timestamp"`
+	User      string    `json:"user"`
+	// Argv are the args mc was invoked with, which allow to re-run the invocation
+	Argv       []string      `json:"argv"`
+	Contexts   []string      `json:"contexts"`
+	Namespaces []string      `json:"namespaces"`
+	Results    []auditResult `json:"results"`
+}
+
+// auditResult is the status of the execution against a single context and namespace
+type auditResult struct {
+	Context   string        `json:"context"`
+	Namespace string        `json:"namespace,omitempty"`
+	Status    string        `json:"status"`
+	Duration  time.Duration `json:"duration"`
+	Error     string        `json:"error,omitempty"`
+}
+
+// history holds the options of the history command
+type history struct {
+	Filter  string
+	Context string
+	Failed  bool
+	Limit   int
+}
+
+// argv reconstructs the args of an invocation from all flags that were set and the kubectl args. Slice and array
+// flags are given once per element, as their string representation can't be parsed again
+func argv(flags *pflag.FlagSet, args []string) []string {
+	var argv []string
+	flags.Visit(func(f *pflag.Flag) {
+		if s, ok := f.Value.(pflag.SliceValue); ok {
+			for _, v := range s.GetSlice() {
+				argv = append(argv, fmt.Sprintf("--%s=%s", f.Name, v))
+			}
+			return
+		}
+		argv = append(argv, fmt.Sprintf("--%s=%s", f.Name, f.Value.String()))
+	})
+	if len(args) > 0 {
+		argv = append(append(argv, "--"), args...)
+	}
+	return argv
+}
+
+// audit appends an entry for an invocation to the audit log
+func (mc *MC) audit(argv []string, contexts []string, results []Result) error {
+	return writeAudit(mc.AuditLog, argv, contexts, strings.Split(mc.Namespaces, ","), results)
+}
+
+// writeAudit appends an entry for an invocation to the audit log at path
+func writeAudit(path string, argv []string, contexts []string, namespaces []string, results []Result) error {
+	entry := auditEntry{
+		Timestamp:  time.Now().UTC(),
+		User:       currentUser(),
+		Argv:       argv,
+		Contexts:   contexts,
+		Namespaces: namespaces,
+	}
+	for _, r := range results {
+		ar := auditResult{Context: r.Context, Namespace: r.Namespace, Status: statusSucceeded, Duration: r.Duration}
+		if r.Err != nil {
+			ar.Status, ar.Error = statusFailed, r.Err.Error()
+		}
+		entry.Results = append(entry.Results, ar)
+	}
+
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// currentUser returns the name of the user running mc
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// readAuditLog reads all entries of the audit log at path. A missing audit log has no entries
+func readAuditLog(path string) ([]auditEntry, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []auditEntry
+	s := bufio.NewScanner(f)
+	s.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for s.Scan() {
+		var e auditEntry
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("couldn't parse audit log %s: %v", path, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, s.Err()
+}
+
+// newHistoryCmd returns the history command, which lists and re-runs previous invocations from the audit log
+func (mc *MC) newHistoryCmd() *cobra.Command {
+	h := &history{}
+	cmd := &cobra.Command{
+		Use:   "history [rerun ID]",
+		Short: "List and re-run previous invocations from the audit log",
+		Example: `
+# list the last 20 invocations against prod clusters
+mc history --context prod --limit 20
+
+# list all invocations that failed on at least one context
+mc history --failed
+
+# re-run the invocation with id 42
+mc history rerun 42`,
+		Args: cobra.MaximumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			entries, err := readAuditLog(mc.AuditLog)
+			if err != nil {
+				return err
+			}
+			if len(args) > 0 {
+				if args[0] != "rerun" || len(args) != 2 {
+					return cmd.Usage()
+				}
+				return mc.rerun(entries, args[1])
+			}
+			return h.list(cmd, entries)
+		},
+	}
+	cmd.Flags().StringVar(&h.Filter, "filter", h.Filter, "a regex to filter the invocations by their command line")
+	cmd.Flags().StringVar(&h.Context, "context", h.Context, "a regex to filter the invocations by the contexts they ran against")
+	cmd.Flags().BoolVar(&h.Failed, "failed", h.Failed, "only list invocations that failed on at least one context")
+	cmd.Flags().IntVar(&h.Limit, "limit", h.Limit, "only list the latest n matching invocations")
+	return cmd
+}
+
+// list prints all entries matching the filters of the history command
+func (h *history) list(cmd *cobra.Command, entries []auditEntry) error {
+	filter, err := regexp.Compile(h.Filter)
+	if err != nil {
+		return err
+	}
+	context, err := regexp.Compile(h.Context)
+	if err != nil {
+		return err
+	}
+
+	type row struct {
+		id    int
+		entry auditEntry
+	}
+	var rows []row
+	for i, e := range entries {
+		if !filter.MatchString(shellJoin(e.Argv)) || !matchesAny(context, e.Contexts) || (h.Failed && failed(e) == 0) {
+			continue
+		}
+		rows = append(rows, row{id: i + 1, entry: e})
+	}
+	if h.Limit > 0 && len(rows) > h.Limit {
+		rows = rows[len(rows)-h.Limit:]
+	}
+
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 8, 3, ' ', 0)
+	fmt.Fprintln(w, "ID\tTIME\tUSER\tCONTEXTS\tFAILED\tCOMMAND")
+	for _, r := range rows {
+		e := r.entry
+		fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%d\tmc %s\n", r.id, e.Timestamp.Local().Format(time.RFC3339), e.User, len(e.Contexts), failed(e), shellJoin(e.Argv))
+	}
+	return w.Flush()
+}
+
+// rerun executes the invocation with the given id again
+func (mc *MC) rerun(entries []auditEntry, id string) error {
+	i, err := strconv.Atoi(id)
+	if err != nil || i < 1 || i > len(entries) {
+		return errUnknownHistoryEntry
+	}
+	logger.Debug("re-running", zap.Strings("argv", entries[i-1].Argv))
+	mc.Cmd.SetArgs(entries[i-1].Argv)
+	return mc.Cmd.Execute()
+}
+
+// failed returns the number of failed executions of an entry
+func failed(e auditEntry) (n int) {
+	for _, r := range e.Results {
+		if r.Status == statusFailed {
+			n++
+		}
+	}
+	return
+}
+
+// matchesAny returns true if re matches any of the strings
+func matchesAny(re *regexp.Regexp, list []string) bool {
+	if re.String() == "" {
+		return true
+	}
+	for _, s := range list {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
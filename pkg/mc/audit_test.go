@@ -0,0 +1,146 @@
+package mc
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jonnylangefeld/kubectl-mc/pkg/mc/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMC_AuditAndHistory(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	list := mocks.NewMockCmd(ctrl)
+	succeeded := mocks.NewMockCmd(ctrl)
+	failed := mocks.NewMockCmd(ctrl)
+	auditLog := filepath.Join(t.TempDir(), auditLogFile)
+
+	list.EXPECT().Output().Return([]byte("kind-kind\nkind-kind1\n"), nil).Times(3)
+	succeeded.EXPECT().Output().Return(kubectlReturn, nil).Times(3)
+	failed.EXPECT().Output().Return(nil, &exec.ExitError{Stderr: []byte("Error: forbidden")}).Times(2)
+
+	mc := New("")
+	mc.getListContextsCmd = func() Cmd {
+		return list
+	}
+	mc.getKubectlCmd = func(ctx context.Context, args []string, c string, namespace string) Cmd {
+		if c == kubeContext {
+			return succeeded
+		}
+		return failed
+	}
+	b := bytes.NewBuffer([]byte(``))
+	mc.Cmd.SetOut(b)
+	mc.Cmd.SetErr(ioutil.Discard)
+	execute := func(args ...string) {
+		b.Reset()
+		mc.Cmd.SetArgs(append([]string{"--audit-log", auditLog}, args...))
+		assert.NoError(t, mc.Cmd.Execute())
+	}
+
+	execute("-r", "kind", "--", "get", "pods")
+	execute("-r", "kind-kind$", "--", "get", "nodes")
+
+	entries, err := readAuditLog(auditLog)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, []string{"--audit-log=" + auditLog, "--regex=kind", "--", "get", "pods"}, entries[0].Argv)
+	assert.Equal(t, []string{"kind-kind", "kind-kind1"}, entries[0].Contexts)
+	assert.Equal(t, []string{""}, entries[0].Namespaces)
+	assert.Equal(t, statusSucceeded, entries[0].Results[0].Status)
+	entries[0].Results[1].Duration = 0
+	assert.Equal(t, auditResult{Context: "kind-kind1", Status: statusFailed, Error: "forbidden"}, entries[0].Results[1])
+	assert.Equal(t, currentUser(), entries[0].User)
+
+	execute("history", "--failed")
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	assert.Len(t, lines, 2)
+	assert.Regexp(t, `^ID\s+TIME\s+USER\s+CONTEXTS\s+FAILED\s+COMMAND$`, lines[0])
+	assert.Regexp(t, `^1\s+.*\s+2\s+1\s+mc --audit-log=.* --regex=kind -- get pods$`, lines[1])
+
+	execute("history", "--context", "kind1")
+	assert.Len(t, strings.Split(strings.TrimSpace(b.String()), "\n"), 2)
+
+	execute("history", "rerun", "1")
+	assert.Contains(t, b.String(), "\nkind-kind1\n----------\nforbidden")
+	entries, err = readAuditLog(auditLog)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 3)
+
+	mc.Cmd.SetArgs([]string{"--audit-log", auditLog, "history", "rerun", "4"})
+	assert.Equal(t, errUnknownHistoryEntry, mc.Cmd.Execute())
+}
+
+func TestArgv(t *testing.T) {
+	mc := New("")
+	assert.NoError(t, mc.Cmd.ParseFlags([]string{"-r", "kind", "-p", "3", "--list-only"}))
+	assert.Equal(t, []string{"--list-only=true", "--max-processes=3", "--regex=kind", "--", "get", "pods"}, argv(mc.Cmd.Flags(), []string{"get", "pods"}))
+
+	mc = New("")
+	assert.NoError(t, mc.Cmd.ParseFlags([]string{"--metric", "a:.x", "--metric", "b:.items[*].y"}))
+	got := argv(mc.Cmd.Flags(), nil)
+	assert.Equal(t, []string{"--metric=a:.x", "--metric=b:.items[*].y"}, got)
+	mc = New("")
+	assert.NoError(t, mc.Cmd.ParseFlags(got))
+	assert.Equal(t, []string{"a:.x", "b:.items[*].y"}, mc.Metrics)
+}
+
+func TestReadAuditLog(t *testing.T) {
+	entries, err := readAuditLog(filepath.Join(t.TempDir(), "missing"))
+	assert.NoError(t, err)
+	assert.Nil(t, entries)
+
+	path := filepath.Join(t.TempDir(), auditLogFile)
+	assert.NoError(t, ioutil.WriteFile(path, []byte("not json\n"), 0600))
+	_, err = readAuditLog(path)
+	assert.Error(t, err)
+}
+
+func TestMC_AuditWatchAndWait(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	list := mocks.NewMockCmd(ctrl)
+	m := mocks.NewMockCmd(ctrl)
+	auditLog := filepath.Join(t.TempDir(), auditLogFile)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	list.EXPECT().Output().Return([]byte("kind-kind\n"), nil).Times(2)
+	m.EXPECT().Output().Return(kubectlReturn, nil)
+	// the watch stops as soon as ctx is canceled, which happens during the second iteration
+	m.EXPECT().Output().DoAndReturn(func() ([]byte, error) {
+		cancel()
+		return kubectlReturn, nil
+	})
+	m.EXPECT().Output().Return(nil, &exitError{Stderr: []byte(`Error from server (NotFound): pods "x" not found`), Code: 1})
+
+	execute := func(ctx context.Context, args ...string) {
+		mc := New("")
+		mc.getListContextsCmd = func() Cmd {
+			return list
+		}
+		mc.getKubectlCmd = func(ctx context.Context, args []string, c string, namespace string) Cmd {
+			return m
+		}
+		mc.Cmd.SetOut(ioutil.Discard)
+		mc.Cmd.SetErr(ioutil.Discard)
+		mc.Cmd.SetArgs(append([]string{"--audit-log", auditLog}, args...))
+		assert.NoError(t, mc.Cmd.ExecuteContext(ctx))
+	}
+	execute(ctx, "--watch", "10ms", "--", "delete", "pod", "x")
+	execute(context.Background(), "wait", "--for", "delete", "--", "pod/x")
+
+	entries, err := readAuditLog(auditLog)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 3)
+	assert.Equal(t, []string{"--audit-log=" + auditLog, "--watch=10ms", "--", "delete", "pod", "x"}, entries[0].Argv)
+	assert.Equal(t, entries[0].Argv, entries[1].Argv)
+	assert.Equal(t, []string{"wait", "--audit-log=" + auditLog, "--for=delete", "--", "pod/x"}, entries[2].Argv)
+	assert.Equal(t, []string{kubeContext}, entries[2].Contexts)
+	assert.Equal(t, statusFailed, entries[2].Results[0].Status)
+}
@@ -0,0 +1,175 @@
+package mc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"go.uber.org/zap"
+)
+
+var (
+	// prewarmArgs are executed once per user to resolve its credentials. The version endpoint is cheap, but still
+	// requires the client to authenticate
+	prewarmArgs = []string{"get", "--raw", "/version"}
+	// rawKubeconfigArgs print the merged kubeconfig including all credentials. Its output is never recorded
+	rawKubeconfigArgs = []string{"config", "view", "--flatten", "--raw", "-o", "json"}
+)
+
+// rawKubeconfig is the complete merged kubeconfig. The entries are kept as is, so no field is lost when writing a
+// minimized copy
+type rawKubeconfig struct {
+	APIVersion     string                   `json:"apiVersion"`
+	Kind           string                   `json:"kind"`
+	Preferences    json.RawMessage          `json:"preferences,omitempty"`
+	Clusters       []map[string]interface{} `json:"clusters"`
+	Contexts       []map[string]interface{} `json:"contexts"`
+	Users          []map[string]interface{} `json:"users"`
+	CurrentContext string                   `json:"current-context"`
+}
+
+// loadRawKubeconfig runs the given command and parses the complete kubeconfig from its output
+func loadRawKubeconfig(cmd Cmd) (*rawKubeconfig, error) {
+	stdout, err := kubectl(cmd)
+	if err != nil {
+		return nil, err
+	}
+	k := &rawKubeconfig{}
+	if err := json.Unmarshal(stdout, k); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// entry returns the entry with the given name
+func entry(entries []map[string]interface{}, name string) map[string]interface{} {
+	for _, e := range entries {
+		if e["name"] == name {
+			return e
+		}
+	}
+	return nil
+}
+
+// contextRef returns the cluster or user the context with the given name references
+func (k *rawKubeconfig) contextRef(name string, ref string) string {
+	c, _ := entry(k.Contexts, name)["context"].(map[string]interface{})
+	s, _ := c[ref].(string)
+	return s
+}
+
+// minify returns a kubeconfig that only contains the context with the given name and its cluster and user
+func (k *rawKubeconfig) minify(name string) *rawKubeconfig {
+	m := &rawKubeconfig{APIVersion: k.APIVersion, Kind: k.Kind, Preferences: k.Preferences, CurrentContext: name}
+	if c := entry(k.Contexts, name); c != nil {
+		m.Contexts = append(m.Contexts, c)
+	}
+	if c := entry(k.Clusters, k.contextRef(name, "cluster")); c != nil {
+		m.Clusters = append(m.Clusters, c)
+	}
+	if u := entry(k.Users, k.contextRef(name, "user")); u != nil {
+		m.Users = append(m.Users, u)
+	}
+	return m
+}
+
+// prewarmAuth resolves the credentials of every unique user of the contexts one after another, so the credential
+// plugins don't race on refreshing the same token once kubectl runs in parallel. kubectl is executed directly, as the
+// proxies of the daemon wouldn't resolve the credentials of the CLI. Failures are ignored, as they are reported by the
+// actual run
+func (mc *MC) prewarmAuth(k *rawKubeconfig, contexts []string) {
+	users := map[string]bool{}
+	for _, c := range contexts {
+		user := k.contextRef(c, "user")
+		if users[user] {
+			continue
+		}
+		users[user] = true
+		logger.Debug("pre-warming credentials", zap.String("user", user), zap.String("context", c))
+		if _, err := mc.directKubectlCmd(context.Background(), prewarmArgs, c, "").Output(); err != nil {
+			logger.Debug("pre-warming credentials failed", zap.String("user", user), zap.Error(kubectlError(err)))
+		}
+	}
+}
+
+// isolateKubeconfig writes a minimized copy of the kubeconfig for every context into a temp dir only accessible by the
+// user, which is used for all kubectl processes of that context. Concurrent writes of credential plugins to the
+// kubeconfig can't collide that way. The returned function removes the temp dir, which is also removed if mc is
+// interrupted or terminated before
+func (mc *MC) isolateKubeconfig(k *rawKubeconfig, contexts []string) (func(), error) {
+	dir, err := ioutil.TempDir("", "kubectl-mc-")
+	if err != nil {
+		return nil, err
+	}
+	stop := mc.removeOnSignal(dir)
+	cleanup := func() {
+		stop()
+		os.RemoveAll(dir)
+	}
+	mc.kubeconfigs = map[string]string{}
+	for i, c := range contexts {
+		b, err := json.Marshal(k.minify(c))
+		if err != nil {
+			cleanup()
+			return nil, err
+		}
+		path := filepath.Join(dir, fmt.Sprintf("%d.json", i))
+		if err := ioutil.WriteFile(path, b, 0600); err != nil {
+			cleanup()
+			return nil, err
+		}
+		mc.kubeconfigs[c] = path
+	}
+	return cleanup, nil
+}
+
+// removeOnSignal removes dir and exits if mc receives an interrupt or termination signal before the returned function
+// is called, as deferred functions don't run on signals
+func (mc *MC) removeOnSignal(dir string) func() {
+	signals := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-signals:
+			os.RemoveAll(dir)
+			mc.exit(1)
+		case <-done:
+		}
+	}()
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
+
+// prepareAuth pre-warms the credentials and isolates the kubeconfig of the contexts, if requested. The returned
+// function cleans up the isolated kubeconfigs
+func (mc *MC) prepareAuth(contexts []string) (func(), error) {
+	cleanup := func() {}
+	if (!mc.PrewarmAuth && !mc.IsolateKubeconfig) || mc.Replay != "" {
+		return cleanup, nil
+	}
+	k, err := loadRawKubeconfig(mc.getRawKubeconfigCmd())
+	if err != nil {
+		return nil, err
+	}
+	if mc.PrewarmAuth {
+		mc.prewarmAuth(k, contexts)
+	}
+	if mc.IsolateKubeconfig {
+		// the kubeconfig is read again, so the copies contain the credentials refreshed by the pre-warm phase
+		if mc.PrewarmAuth {
+			if k, err = loadRawKubeconfig(mc.getRawKubeconfigCmd()); err != nil {
+				return nil, err
+			}
+		}
+		return mc.isolateKubeconfig(k, contexts)
+	}
+	return cleanup, nil
+}
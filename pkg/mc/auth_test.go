@@ -0,0 +1,167 @@
+package mc
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jonnylangefeld/kubectl-mc/pkg/mc/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+var rawKubeconfigView = []byte(`{
+  "apiVersion": "v1",
+  "kind": "Config",
+  "preferences": {},
+  "clusters": [
+    {"name": "kind-kind", "cluster": {"server": "https://127.0.0.1:6443", "certificate-authority-data": "Y2E="}},
+    {"name": "prod", "cluster": {"server": "https://prod.example.com"}}
+  ],
+  "contexts": [
+    {"name": "kind-kind", "context": {"cluster": "kind-kind", "user": "kind-kind"}},
+    {"name": "kind-kind1", "context": {"cluster": "kind-kind", "user": "kind-kind", "namespace": "kube-system"}},
+    {"name": "prod", "context": {"cluster": "prod", "user": "sso"}}
+  ],
+  "users": [
+    {"name": "kind-kind", "user": {"token": "secret"}},
+    {"name": "sso", "user": {"exec": {"command": "aws", "args": ["eks", "get-token"]}}}
+  ],
+  "current-context": "prod"
+}`)
+
+func TestRawKubeconfig_Minify(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	m := mocks.NewMockCmd(ctrl)
+	m.EXPECT().Output().Return(rawKubeconfigView, nil)
+
+	k, err := loadRawKubeconfig(m)
+	assert.NoError(t, err)
+
+	b, err := json.Marshal(k.minify("kind-kind1"))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+  "apiVersion": "v1",
+  "kind": "Config",
+  "preferences": {},
+  "clusters": [{"name": "kind-kind", "cluster": {"server": "https://127.0.0.1:6443", "certificate-authority-data": "Y2E="}}],
+  "contexts": [{"name": "kind-kind1", "context": {"cluster": "kind-kind", "user": "kind-kind", "namespace": "kube-system"}}],
+  "users": [{"name": "kind-kind", "user": {"token": "secret"}}],
+  "current-context": "kind-kind1"
+}`, string(b))
+}
+
+func TestMC_PrewarmAuthAndIsolateKubeconfig(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	list := mocks.NewMockCmd(ctrl)
+	raw := mocks.NewMockCmd(ctrl)
+	m := mocks.NewMockCmd(ctrl)
+
+	list.EXPECT().Output().Return([]byte("kind-kind\nkind-kind1\nprod\n"), nil)
+	raw.EXPECT().Output().Return(rawKubeconfigView, nil).Times(2)
+	m.EXPECT().Output().Return([]byte("ok\n"), nil).Times(5)
+
+	var mutex sync.Mutex
+	var calls []string
+	kubeconfigs := map[string]string{}
+	mc := New("")
+	mc.getListContextsCmd = func() Cmd {
+		return list
+	}
+	mc.getRawKubeconfigCmd = func() Cmd {
+		return raw
+	}
+	mc.getKubectlCmd = func(ctx context.Context, args []string, c string, namespace string) Cmd {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if args[0] == "--kubeconfig" {
+			kubeconfigs[c] = args[1]
+			b, err := ioutil.ReadFile(args[1])
+			assert.NoError(t, err)
+			assert.Contains(t, string(b), `"current-context":"`+c+`"`)
+			args = args[2:]
+		}
+		calls = append(calls, c+": "+strings.Join(args, " "))
+		return m
+	}
+	mc.Cmd.SetOut(ioutil.Discard)
+	mc.Cmd.SetArgs([]string{"--prewarm-auth", "--isolate-kubeconfig", "--", "get", "pods"})
+	assert.NoError(t, mc.Cmd.Execute())
+
+	// the credentials are resolved once per user before the fan-out
+	assert.Equal(t, []string{"kind-kind: get --raw /version", "prod: get --raw /version"}, calls[:2])
+	rest := calls[2:]
+	sort.Strings(rest)
+	assert.Equal(t, []string{"kind-kind1: get pods", "kind-kind: get pods", "prod: get pods"}, rest)
+
+	// the isolated kubeconfigs are removed after the run
+	assert.Len(t, kubeconfigs, 3)
+	for _, path := range kubeconfigs {
+		_, err := os.Stat(path)
+		assert.True(t, os.IsNotExist(err))
+	}
+}
+
+func TestMC_RemoveOnSignal(t *testing.T) {
+	dir := t.TempDir()
+	exited := make(chan int, 1)
+	mc := New("")
+	mc.exit = func(code int) {
+		exited <- code
+	}
+	stop := mc.removeOnSignal(dir)
+	defer stop()
+
+	p, err := os.FindProcess(os.Getpid())
+	assert.NoError(t, err)
+	assert.NoError(t, p.Signal(syscall.SIGTERM))
+	select {
+	case code := <-exited:
+		assert.Equal(t, 1, code)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the signal wasn't handled")
+	}
+	_, err = os.Stat(dir)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestMC_PrewarmAuthBypassesDaemon(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	list := mocks.NewMockCmd(ctrl)
+	raw := mocks.NewMockCmd(ctrl)
+	direct := mocks.NewMockCmd(ctrl)
+	delegated := mocks.NewMockCmd(ctrl)
+
+	list.EXPECT().Output().Return([]byte("kind-kind\n"), nil)
+	raw.EXPECT().Output().Return(rawKubeconfigView, nil)
+	direct.EXPECT().Output().Return([]byte("ok\n"), nil)
+	delegated.EXPECT().Output().Return(kubectlReturn, nil)
+
+	d, calls := startTestDaemon(t, delegated)
+	var directCalls [][]string
+	mc := New("")
+	mc.getListContextsCmd = func() Cmd {
+		return list
+	}
+	mc.getRawKubeconfigCmd = func() Cmd {
+		return raw
+	}
+	mc.getKubectlCmd = func(ctx context.Context, args []string, c string, namespace string) Cmd {
+		directCalls = append(directCalls, args)
+		return direct
+	}
+	mc.Cmd.SetOut(ioutil.Discard)
+	mc.Cmd.SetArgs([]string{"--daemon-socket", d.socket, "--prewarm-auth", "--", "get", "pods"})
+	assert.NoError(t, mc.Cmd.Execute())
+
+	// the credentials are resolved by kubectl itself, only the run is delegated to the daemon
+	assert.Equal(t, prewarmArgs, directCalls[0])
+	assert.Len(t, *calls, 1)
+}
@@ -0,0 +1,212 @@
+package mc
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	cacheDir = "cache"
+	// cacheAgeKey is the field marking cached json objects in structured output
+	cacheAgeKey = "mcCacheAge"
+)
+
+var (
+	// readOnlyVerbs are the kubectl verbs that don't change the state of a cluster
+	readOnlyVerbs = []string{"get", "describe", "top", "logs", "events", "explain", "api-resources", "api-versions", "version", "cluster-info"}
+	// streamingFlags make a read-only command run until it is interrupted, so its output is never cached
+	streamingFlags = []string{"-w", "--watch", "--watch-only", "-f", "--follow"}
+)
+
+// cacheEntry is the stdout of a successful execution and when it was executed
+type cacheEntry struct {
+	Time   time.Time `json:"time"`
+	Stdout []byte    `json:"stdout"`
+}
+
+// cacheCmd wraps a Cmd and serves its stdout from the cache in dir while it is younger than ttl. If refresh is set the
+// command is always executed. Only successful executions are cached
+type cacheCmd struct {
+	cmd     Cmd
+	dir     string
+	key     string
+	ttl     time.Duration
+	refresh bool
+	// cachedAge is the age of the cache entry the output was served from
+	cachedAge time.Duration
+}
+
+// Output returns the cached stdout if there is a fresh entry and executes the wrapped command otherwise
+func (c *cacheCmd) Output() ([]byte, error) {
+	path := filepath.Join(c.dir, c.key+".json")
+	if !c.refresh {
+		if e, err := readCacheEntry(path); err == nil && time.Since(e.Time) < c.ttl {
+			c.cachedAge = time.Since(e.Time)
+			return e.Stdout, nil
+		}
+	}
+	stdout, err := c.cmd.Output()
+	if err != nil {
+		return stdout, err
+	}
+	if werr := writeCacheEntry(path, cacheEntry{Time: time.Now(), Stdout: stdout}); werr != nil {
+		logger.Debug("couldn't write cache entry", zap.String("file", path), zap.Error(werr))
+	}
+	return stdout, nil
+}
+
+// age implements the aged interface
+func (c *cacheCmd) age() time.Duration {
+	return c.cachedAge
+}
+
+// aged is implemented by commands whose output can be older than the execution
+type aged interface {
+	age() time.Duration
+}
+
+// readCacheEntry reads the cache entry at path
+func readCacheEntry(path string) (e cacheEntry, err error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return e, err
+	}
+	return e, json.Unmarshal(b, &e)
+}
+
+// writeCacheEntry writes e to path. Outputs can contain secrets, so only the current user can read them
+func writeCacheEntry(path string, e cacheEntry) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0600)
+}
+
+// cacheable returns true if the output of args can be cached
+func cacheable(args []string) bool {
+	return contains(readOnlyVerbs, kubectlVerb(args)) && !streaming(args)
+}
+
+// streaming returns true if args contain a flag that makes kubectl run until it is interrupted
+func streaming(args []string) bool {
+	for _, arg := range args {
+		for _, f := range streamingFlags {
+			if arg == f || strings.HasPrefix(arg, f+"=") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// cacheKey returns the cache key of an execution of args against a context and namespace. The key includes the
+// kubeconfig, as context names aren't unique across kubeconfigs, and flags given as `--flag=value` are normalized to
+// `--flag value`
+func cacheKey(args []string, context string, namespace string) string {
+	normalized := []string{os.Getenv("KUBECONFIG"), context, namespace}
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") && strings.Contains(arg, "=") {
+			normalized = append(normalized, strings.SplitN(arg, "=", 2)...)
+			continue
+		}
+		normalized = append(normalized, arg)
+	}
+	h := sha256.Sum256([]byte(strings.Join(normalized, "\x00")))
+	return hex.EncodeToString(h[:])
+}
+
+// cached returns cmd wrapped to be served from the cache, if caching is enabled and args are cacheable
+func (mc *MC) cached(cmd Cmd, args []string, context string, namespace string) Cmd {
+	if mc.CacheTTL <= 0 || mc.Record != "" || mc.Replay != "" || !cacheable(args) {
+		return cmd
+	}
+	return &cacheCmd{
+		cmd:     cmd,
+		dir:     mc.CacheDir,
+		key:     cacheKey(args, context, namespace),
+		ttl:     mc.CacheTTL,
+		refresh: mc.NoCache,
+	}
+}
+
+// cacheAge returns the age of a cached result as shown in text output
+func cacheAge(r Result) string {
+	return fmt.Sprintf("(cached %s ago)", r.Age.Round(time.Second))
+}
+
+// markCached adds the age of a cached result to the top-level metadata of its stdout, if it is a json object. The
+// field is spliced into the stdout, so that the order of the keys stays the same as in fresh output
+func markCached(r Result) json.RawMessage {
+	if r.Age <= 0 {
+		return r.Stdout
+	}
+	field, _ := json.Marshal(r.Age.Round(time.Second).String())
+	field = append([]byte(`"`+cacheAgeKey+`":`), field...)
+	b, ok := spliceMetadata(r.Stdout, field)
+	if !ok {
+		return r.Stdout
+	}
+	return b
+}
+
+// spliceMetadata inserts field as first field of the top-level metadata of the json object in b. If there is no
+// metadata, it is added as last field of the object. It returns false if b isn't a json object
+func spliceMetadata(b []byte, field []byte) ([]byte, bool) {
+	if !json.Valid(b) {
+		return nil, false
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return nil, false
+	}
+	empty := true
+	for dec.More() {
+		empty = false
+		key, err := dec.Token()
+		if err != nil {
+			return nil, false
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, false
+		}
+		if key != "metadata" || value[0] != '{' {
+			continue
+		}
+		// the metadata ends at the current offset, so its opening brace is at the offset minus its length
+		if len(bytes.TrimSpace(value[1:len(value)-1])) > 0 {
+			field = append(field, ',')
+		}
+		return splice(b, int(dec.InputOffset())-len(value)+1, field), true
+	}
+	if t, err := dec.Token(); err != nil || t != json.Delim('}') {
+		return nil, false
+	}
+	field = append([]byte(`"metadata":{`), append(field, '}')...)
+	if !empty {
+		field = append([]byte{','}, field...)
+	}
+	return splice(b, int(dec.InputOffset())-1, field), true
+}
+
+// splice returns a copy of b with insert inserted at i
+func splice(b []byte, i int, insert []byte) []byte {
+	out := make([]byte, 0, len(b)+len(insert))
+	out = append(out, b[:i]...)
+	out = append(out, insert...)
+	return append(out, b[i:]...)
+}
@@ -0,0 +1,100 @@
+package mc
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jonnylangefeld/kubectl-mc/pkg/mc/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheable(t *testing.T) {
+	tests := map[string]struct {
+		args []string
+		want bool
+	}{
+		"get":        {args: []string{"get", "nodes"}, want: true},
+		"describe":   {args: []string{"describe", "pod", "x"}, want: true},
+		"apply":      {args: []string{"apply", "-f", "x.yaml"}, want: false},
+		"watch":      {args: []string{"get", "pods", "-w"}, want: false},
+		"follow":     {args: []string{"logs", "x", "--follow=true"}, want: false},
+		"delete":     {args: []string{"delete", "pod", "x"}, want: false},
+		"no command": {args: []string{}, want: false},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.want, cacheable(test.args))
+		})
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	key := cacheKey([]string{"get", "pods", "-o", "json"}, kubeContext, namespace)
+	assert.Equal(t, key, cacheKey([]string{"get", "pods", "-o=json"}, kubeContext, namespace))
+	assert.NotEqual(t, key, cacheKey([]string{"get", "pods", "-o", "json"}, kubeContext, "kube-system"))
+	assert.NotEqual(t, key, cacheKey([]string{"get", "pods", "-o", "json"}, "kind-kind1", namespace))
+	assert.NotEqual(t, key, cacheKey([]string{"get", "pods", "-o", "yaml"}, kubeContext, namespace))
+}
+
+func TestMC_Cache(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	list := mocks.NewMockCmd(ctrl)
+	m := mocks.NewMockCmd(ctrl)
+	list.EXPECT().Output().Return([]byte("kind-kind\n"), nil).AnyTimes()
+	// the second and third invocation are served from the cache, --no-cache executes again
+	m.EXPECT().Output().Return([]byte(`{"kind":"List","items":[{"kind":"Pod"}]}`), nil).Times(2)
+	dir := t.TempDir()
+
+	execute := func(args ...string) string {
+		mc := New("")
+		mc.getListContextsCmd = func() Cmd {
+			return list
+		}
+		mc.getKubectlCmd = func(ctx context.Context, args []string, c string, namespace string) Cmd {
+			return m
+		}
+		b := bytes.NewBuffer([]byte(``))
+		mc.Cmd.SetOut(b)
+		mc.Cmd.SetArgs(append([]string{"--cache-ttl", "1m", "--cache-dir", dir, "-n", "cache-test"}, args...))
+		assert.NoError(t, mc.Cmd.Execute())
+		return b.String()
+	}
+
+	assert.Equal(t, formatContext(kubeContext, "cache-test", []byte(`{"kind":"List","items":[{"kind":"Pod"}]}`)), execute("--", "get", "pods", "-o", "json"))
+	assert.Equal(t, formatContext(kubeContext+": cache-test (cached 0s ago)", "", []byte(`{"kind":"List","items":[{"kind":"Pod"}]}`)), execute("--", "get", "pods", "-o=json"))
+	assert.JSONEq(t, `{"kind-kind: cache-test": {"kind": "List", "items": [{"kind": "Pod"}], "metadata": {"mcCacheAge": "0s"}}}`, execute("-o", "json", "--", "get", "pods"))
+	assert.Equal(t, "CONTEXT,NAMESPACE,CACHE AGE,KIND\nkind-kind,cache-test,0s,Pod\n", execute("-o", "csv", "--columns", "KIND:.kind", "--", "get", "pods"))
+	assert.Contains(t, execute("-o", "prometheus", "--", "get", "pods"), `mc_items{context="kind-kind",namespace="cache-test",cache_age_seconds="0"} 1`)
+	outputDir := t.TempDir()
+	execute("-o", "json", "--output-dir", outputDir, "--", "get", "pods")
+	index, err := ioutil.ReadFile(filepath.Join(outputDir, outputDirIndex))
+	assert.NoError(t, err)
+	assert.Contains(t, string(index), "cacheAge: 0s\n")
+	assert.Equal(t, formatContext(kubeContext, "cache-test", []byte(`{"kind":"List","items":[{"kind":"Pod"}]}`)), execute("--no-cache", "--", "get", "pods", "-o", "json"))
+}
+
+func TestMarkCached(t *testing.T) {
+	tests := map[string]struct {
+		stdout string
+		want   string
+	}{
+		"metadata":       {stdout: `{"kind":"Pod","metadata":{"name":"x","namespace":"a"},"spec":{}}`, want: `{"kind":"Pod","metadata":{"mcCacheAge":"1m12s","name":"x","namespace":"a"},"spec":{}}`},
+		"empty metadata": {stdout: `{"kind": "List", "metadata": { }, "items": []}`, want: `{"kind": "List", "metadata": {"mcCacheAge":"1m12s" }, "items": []}`},
+		"no metadata":    {stdout: `{"kind":"List","items":[]}`, want: `{"kind":"List","items":[],"metadata":{"mcCacheAge":"1m12s"}}`},
+		"empty object":   {stdout: `{}`, want: `{"metadata":{"mcCacheAge":"1m12s"}}`},
+		"nested only":    {stdout: `{"items":[{"metadata":{}}]}`, want: `{"items":[{"metadata":{}}],"metadata":{"mcCacheAge":"1m12s"}}`},
+		"no object":      {stdout: `[1,2]`, want: `[1,2]`},
+		"no json":        {stdout: `NAME  READY`, want: `NAME  READY`},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.want, string(markCached(Result{Stdout: []byte(test.stdout), Age: 72 * time.Second})))
+		})
+	}
+	assert.Equal(t, `{"kind":"List"}`, string(markCached(Result{Stdout: []byte(`{"kind":"List"}`)})))
+}
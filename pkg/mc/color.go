@@ -0,0 +1,180 @@
+package mc
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const (
+	colorAuto   = "auto"
+	colorAlways = "always"
+	colorNever  = "never"
+
+	ansiBold    = "1"
+	ansiDim     = "2"
+	ansiRed     = "31"
+	ansiGreen   = "32"
+	ansiBlue    = "34"
+	ansiMagenta = "35"
+	ansiCyan    = "36"
+)
+
+var (
+	// contextColors are the colors of the context headers. Red is left out, as it marks failed contexts
+	contextColors = []string{"32", "33", "34", "35", "36", "92", "93", "94", "95", "96"}
+
+	yamlLine   = regexp.MustCompile(`^(\s*(?:- )*)([^\s#'"][^:#]*|"[^"]*"|'[^']*')(:)(\s.*|$)`)
+	yamlNumber = regexp.MustCompile(`^-?[0-9][0-9_.eE+-]*$`)
+
+	errUnknownColor = fmt.Errorf("this color option is unknown. Choose one of %s|%s|%s", colorAuto, colorAlways, colorNever)
+)
+
+// useColor returns whether the output should be colored. For auto, color is used if stdout is a terminal and the
+// NO_COLOR environment variable isn't set
+func (mc *MC) useColor() (bool, error) {
+	switch mc.Color {
+	case colorAlways:
+		return true, nil
+	case colorNever:
+		return false, nil
+	case colorAuto:
+		return os.Getenv("NO_COLOR") == "" && isTerminal(mc.Cmd.OutOrStdout()), nil
+	}
+	return false, errUnknownColor
+}
+
+// isTerminal returns true if w is a terminal
+func isTerminal(w interface{}) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// colorize wraps s into the ANSI escape codes of the given color
+func colorize(color string, s string) string {
+	return "\x1b[" + color + "m" + s + "\x1b[0m"
+}
+
+// contextColor returns the color of a context, which is derived from its name so it is the same in every run
+func contextColor(context string) string {
+	h := fnv.New32a()
+	h.Write([]byte(context))
+	return contextColors[h.Sum32()%uint32(len(contextColors))]
+}
+
+// formatText formats body for the text output of a result. With color the header is printed in the color of the
+// context, or red if the execution failed, in which case the body is the dimmed stderr. Cached results show their age
+// in the header
+func (mc *MC) formatText(r Result, body string) string {
+	header := r.Context
+	if r.Namespace != "" {
+		header += ": " + r.Namespace
+	}
+	if r.Age > 0 {
+		header += " " + cacheAge(r)
+	}
+	if !mc.color {
+		return formatContext(header, "", []byte(body))
+	}
+	color := contextColor(r.Context)
+	if r.Err != nil {
+		color = ansiRed
+		body = colorize(ansiDim, body)
+	}
+	return fmt.Sprintf("\n%s\n%s\n%s", colorize(ansiBold+";"+color, header), colorize(color, strings.Repeat("-", len(header))), body)
+}
+
+// writeStructured writes json or yaml output, syntax-highlighted if color is enabled
+func (mc *MC) writeStructured(out io.Writer, o []byte) {
+	s := string(o)
+	if mc.color {
+		if mc.Output == JSON {
+			s = highlightJSON(s)
+		} else {
+			s = highlightYAML(s)
+		}
+	}
+	fmt.Fprint(out, s)
+}
+
+// highlightJSON colors the keys, strings, numbers and literals of indented json
+func highlightJSON(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '"':
+			end := i + 1
+			for end < len(s) && s[end] != '"' {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end < len(s) {
+				end++
+			}
+			color := ansiGreen
+			if rest := strings.TrimLeft(s[end:], " "); strings.HasPrefix(rest, ":") {
+				color = ansiBlue
+			}
+			b.WriteString(colorize(color, s[i:end]))
+			i = end
+		case c == '-' || (c >= '0' && c <= '9'):
+			end := i + 1
+			for end < len(s) && strings.IndexByte("0123456789.eE+-", s[end]) >= 0 {
+				end++
+			}
+			b.WriteString(colorize(ansiCyan, s[i:end]))
+			i = end
+		case strings.HasPrefix(s[i:], "true") || strings.HasPrefix(s[i:], "false") || strings.HasPrefix(s[i:], "null"):
+			end := i + strings.IndexAny(s[i:]+",", ",\n }]")
+			b.WriteString(colorize(ansiMagenta, s[i:end]))
+			i = end
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String()
+}
+
+// highlightYAML colors the keys and scalar values of yaml line by line
+func highlightYAML(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		if m := yamlLine.FindStringSubmatch(l); m != nil {
+			value := strings.TrimSpace(m[4])
+			lines[i] = m[1] + colorize(ansiBlue, m[2]) + m[3]
+			if value != "" {
+				lines[i] += " " + highlightYAMLScalar(value)
+			}
+			continue
+		}
+		trimmed := strings.TrimLeft(l, " ")
+		if strings.HasPrefix(trimmed, "- ") {
+			lines[i] = l[:len(l)-len(trimmed)] + "- " + highlightYAMLScalar(trimmed[2:])
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// highlightYAMLScalar colors a single yaml value by its type
+func highlightYAMLScalar(v string) string {
+	switch {
+	case v == "|" || v == "|-" || v == ">" || v == ">-" || v == "{}" || v == "[]":
+		return v
+	case v == "true" || v == "false" || v == "null":
+		return colorize(ansiMagenta, v)
+	case yamlNumber.MatchString(v):
+		return colorize(ansiCyan, v)
+	}
+	return colorize(ansiGreen, v)
+}
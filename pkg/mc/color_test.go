@@ -0,0 +1,89 @@
+package mc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jonnylangefeld/kubectl-mc/pkg/mc/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMC_UseColor(t *testing.T) {
+	tests := map[string]struct {
+		color   string
+		noColor string
+		want    bool
+		wantErr error
+	}{
+		"always":               {color: colorAlways, noColor: "1", want: true},
+		"never":                {color: colorNever},
+		"auto without a tty":   {color: colorAuto},
+		"auto with NO_COLOR":   {color: colorAuto, noColor: "1"},
+		"unknown color option": {color: "sometimes", wantErr: errUnknownColor},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			os.Setenv("NO_COLOR", test.noColor)
+			defer os.Unsetenv("NO_COLOR")
+			mc := New("")
+			mc.Color = test.color
+			mc.Cmd.SetOut(&bytes.Buffer{})
+			got, err := mc.useColor()
+			assert.Equal(t, test.wantErr, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestContextColor(t *testing.T) {
+	assert.Equal(t, contextColor(kubeContext), contextColor(kubeContext))
+	for _, c := range []string{kubeContext, "kind-kind1", "prod-eu", "prod-us"} {
+		assert.NotEqual(t, ansiRed, contextColor(c))
+	}
+}
+
+func TestMC_FormatText(t *testing.T) {
+	r := Result{Context: kubeContext, Namespace: namespace}
+	assert.Equal(t, "\nkind-kind: default\n------------------\nout\n", (&MC{}).formatText(r, "out\n"))
+
+	c := contextColor(kubeContext)
+	assert.Equal(t, "\n\x1b[1;"+c+"mkind-kind: default\x1b[0m\n\x1b["+c+"m------------------\x1b[0m\nout\n", (&MC{color: true}).formatText(r, "out\n"))
+
+	r.Err = fmt.Errorf("connection refused")
+	assert.Equal(t, "\n\x1b[1;31mkind-kind: default\x1b[0m\n\x1b[31m------------------\x1b[0m\n\x1b[2mconnection refused\x1b[0m", (&MC{color: true}).formatText(r, "connection refused"))
+}
+
+func TestHighlightJSON(t *testing.T) {
+	got := highlightJSON("{\n  \"a\": \"x \\\" y\",\n  \"b\": [-1.5, true, null]\n}")
+	assert.Equal(t, "{\n  \x1b[34m\"a\"\x1b[0m: \x1b[32m\"x \\\" y\"\x1b[0m,\n  \x1b[34m\"b\"\x1b[0m: [\x1b[36m-1.5\x1b[0m, \x1b[35mtrue\x1b[0m, \x1b[35mnull\x1b[0m]\n}", got)
+}
+
+func TestHighlightYAML(t *testing.T) {
+	got := highlightYAML("kind-kind:\n  items:\n  - name: a\n    replicas: 3\n    ready: false\n  - b\n  labels: {}\n")
+	assert.Equal(t, "\x1b[34mkind-kind\x1b[0m:\n  \x1b[34mitems\x1b[0m:\n  - \x1b[34mname\x1b[0m: \x1b[32ma\x1b[0m\n    \x1b[34mreplicas\x1b[0m: \x1b[36m3\x1b[0m\n    \x1b[34mready\x1b[0m: \x1b[35mfalse\x1b[0m\n  - \x1b[32mb\x1b[0m\n  \x1b[34mlabels\x1b[0m: {}\n", got)
+}
+
+func TestMC_Color(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	list := mocks.NewMockCmd(ctrl)
+	m := mocks.NewMockCmd(ctrl)
+	list.EXPECT().Output().Return([]byte("kind-kind\n"), nil)
+	m.EXPECT().Output().Return([]byte(`{"a":1}`), nil)
+
+	mc := New("")
+	mc.getListContextsCmd = func() Cmd {
+		return list
+	}
+	mc.getKubectlCmd = func(ctx context.Context, args []string, c string, namespace string) Cmd {
+		return m
+	}
+	b := bytes.NewBuffer([]byte(``))
+	mc.Cmd.SetOut(b)
+	mc.Cmd.SetArgs([]string{"--color", "always", "-o", "json", "--", "get", "pods"})
+	assert.NoError(t, mc.Cmd.Execute())
+	assert.Equal(t, "{\n  \x1b[34m\"kind-kind\"\x1b[0m: {\n    \x1b[34m\"a\"\x1b[0m: \x1b[36m1\x1b[0m\n  }\n}", b.String())
+}
@@ -0,0 +1,143 @@
+package mc
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// newCompletionCmd returns the command that generates the shell completion scripts
+func (mc *MC) newCompletionCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "completion bash|zsh|fish|powershell",
+		Short: "Generate the shell completion script for contexts, groups and kubectl args",
+		Example: `
+# load the completion into the current bash session
+source <(kubectl-mc completion bash)
+
+# load the completion for every new zsh session
+kubectl-mc completion zsh > "${fpath[1]}/_kubectl-mc"`,
+		ValidArgs: []string{"bash", "zsh", "fish", "powershell"},
+		Args:      cobra.ExactValidArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root, out := cmd.Root(), cmd.OutOrStdout()
+			switch args[0] {
+			case "bash":
+				return root.GenBashCompletion(out)
+			case "zsh":
+				return root.GenZshCompletion(out)
+			case "fish":
+				return root.GenFishCompletion(out, true)
+			}
+			return root.GenPowerShellCompletion(out)
+		},
+	}
+}
+
+// completeRegex completes the regex flags with the names of all groups of the config file and all contexts of the
+// kubeconfig. A partially typed regex is suggested as well, together with the amount of contexts it matches
+func (mc *MC) completeRegex(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	stdout, err := kubectl(mc.getListContextsCmd())
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	var contexts []string
+	s := bufio.NewScanner(bytes.NewReader(stdout))
+	for s.Scan() {
+		contexts = append(contexts, s.Text())
+	}
+	c, err := loadConfig(mc.ConfigPath)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	var completions []string
+	if toComplete != "" {
+		if re, err := regexp.Compile(toComplete); err == nil {
+			completions = append(completions, fmt.Sprintf("%s\tmatches %d contexts", toComplete, countMatches(re, contexts)))
+		}
+	}
+	groups := make([]string, 0, len(c.Groups))
+	for g := range c.Groups {
+		groups = append(groups, g)
+	}
+	sort.Strings(groups)
+	for _, g := range groups {
+		if !strings.HasPrefix(g, toComplete) || g == toComplete {
+			continue
+		}
+		desc := "group"
+		if re, err := regexp.Compile(c.Groups[g]); err == nil {
+			desc = fmt.Sprintf("group matching %d contexts", countMatches(re, contexts))
+		}
+		completions = append(completions, fmt.Sprintf("%s\t%s", g, desc))
+	}
+	for _, ctx := range contexts {
+		if strings.HasPrefix(ctx, toComplete) && ctx != toComplete {
+			completions = append(completions, ctx+"\tcontext")
+		}
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeKubectlArgs delegates the completion of the kubectl args to `kubectl __complete`, using the first context
+// and namespace that are selected by the flags
+func (mc *MC) completeKubectlArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	c, err := loadConfig(mc.ConfigPath)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	r := NewRunner(Options{Regex: c.expandGroup(mc.Regex), NegRegex: c.expandGroup(mc.NegRegex)})
+	contexts, err := r.listContexts(mc.getListContextsCmd())
+	if err != nil || len(contexts) == 0 {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	completeArgs := []string{cobra.ShellCompRequestCmd, "--context", contexts[0]}
+	if ns := strings.Split(mc.Namespaces, ",")[0]; ns != "" {
+		completeArgs = append(completeArgs, "--namespace", ns)
+	}
+	completeArgs = append(append(completeArgs, args...), toComplete)
+	stdout, err := kubectl(mc.getCompletionCmd(completeArgs))
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	return parseCompletions(stdout)
+}
+
+// parseCompletions parses the output of a cobra __complete command. Every line is a completion, except for the last
+// one, which is the directive in the format `:<directive>`
+func parseCompletions(stdout []byte) ([]string, cobra.ShellCompDirective) {
+	lines := strings.Split(strings.TrimSpace(string(stdout)), "\n")
+	last := lines[len(lines)-1]
+	if !strings.HasPrefix(last, ":") {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	directive, err := strconv.Atoi(last[1:])
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	var completions []string
+	for _, l := range lines[:len(lines)-1] {
+		if l != "" {
+			completions = append(completions, l)
+		}
+	}
+	return completions, cobra.ShellCompDirective(directive)
+}
+
+// countMatches returns the amount of strings in list that match re
+func countMatches(re *regexp.Regexp, list []string) (n int) {
+	for _, s := range list {
+		if re.MatchString(s) {
+			n++
+		}
+	}
+	return
+}
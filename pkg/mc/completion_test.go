@@ -0,0 +1,102 @@
+package mc
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jonnylangefeld/kubectl-mc/pkg/mc/mocks"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMC_CompleteRegex(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	m := mocks.NewMockCmd(ctrl)
+	configPath := filepath.Join(t.TempDir(), configFile)
+	assert.NoError(t, ioutil.WriteFile(configPath, []byte("groups:\n  kinds: kind-kind\\d\n"), 0644))
+
+	tests := map[string]struct {
+		args []string
+		want string
+	}{
+		"empty": {
+			args: []string{"__complete", "--config", configPath, "-r", ""},
+			want: "kinds\tgroup matching 2 contexts\nkind-kind\tcontext\nkind-kind1\tcontext\nkind-kind2\tcontext\n:4\n",
+		},
+		"partial regex": {
+			args: []string{"__complete", "--config", configPath, "-x", "kind-kind"},
+			want: "kind-kind\tmatches 3 contexts\nkind-kind1\tcontext\nkind-kind2\tcontext\n:4\n",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			m.EXPECT().Output().Return([]byte("kind-kind\nkind-kind1\nkind-kind2\n"), nil)
+			mc := New("")
+			mc.getListContextsCmd = func() Cmd {
+				return m
+			}
+			b := bytes.NewBuffer([]byte(``))
+			mc.Cmd.SetOut(b)
+			mc.Cmd.SetErr(ioutil.Discard)
+			mc.Cmd.SetArgs(test.args)
+			assert.NoError(t, mc.Cmd.Execute())
+			assert.Equal(t, test.want, b.String())
+		})
+	}
+}
+
+func TestMC_CompleteKubectlArgs(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	list := mocks.NewMockCmd(ctrl)
+	complete := mocks.NewMockCmd(ctrl)
+	configPath := filepath.Join(t.TempDir(), configFile)
+	assert.NoError(t, ioutil.WriteFile(configPath, []byte("groups:\n  kinds: kind-kind\\d\n"), 0644))
+
+	list.EXPECT().Output().Return([]byte("kind-kind\nkind-kind1\nkind-kind2\n"), nil)
+	complete.EXPECT().Output().Return([]byte("coredns-66bff467f8-4lnsg\ncoredns-66bff467f8-zvt5l\n:4\n"), nil)
+
+	mc := New("")
+	mc.getListContextsCmd = func() Cmd {
+		return list
+	}
+	var gotArgs []string
+	mc.getCompletionCmd = func(args []string) Cmd {
+		gotArgs = args
+		return complete
+	}
+	b := bytes.NewBuffer([]byte(``))
+	mc.Cmd.SetOut(b)
+	mc.Cmd.SetErr(ioutil.Discard)
+	mc.Cmd.SetArgs([]string{"__complete", "--config", configPath, "-r", "kinds", "-n", "kube-system,default", "--", "get", "pods", "core"})
+	assert.NoError(t, mc.Cmd.Execute())
+	assert.Equal(t, []string{"__complete", "--context", "kind-kind1", "--namespace", "kube-system", "get", "pods", "core"}, gotArgs)
+	assert.Equal(t, "coredns-66bff467f8-4lnsg\ncoredns-66bff467f8-zvt5l\n:4\n", b.String())
+}
+
+func TestParseCompletions(t *testing.T) {
+	got, directive := parseCompletions([]byte("pods\tPod\nservices\n:4\n"))
+	assert.Equal(t, []string{"pods\tPod", "services"}, got)
+	assert.Equal(t, cobra.ShellCompDirectiveNoFileComp, directive)
+
+	_, directive = parseCompletions([]byte("pods\n"))
+	assert.Equal(t, cobra.ShellCompDirectiveError, directive)
+}
+
+func TestMC_CompletionCmd(t *testing.T) {
+	mc := New("")
+	b := bytes.NewBuffer([]byte(``))
+	mc.Cmd.SetOut(b)
+	mc.Cmd.SetArgs([]string{"completion", "bash"})
+	assert.NoError(t, mc.Cmd.Execute())
+	assert.Contains(t, b.String(), "__complete")
+
+	mc = New("")
+	mc.Cmd.SetOut(ioutil.Discard)
+	mc.Cmd.SetErr(ioutil.Discard)
+	mc.Cmd.SetArgs([]string{"completion", "tcsh"})
+	assert.Error(t, mc.Cmd.Execute())
+}
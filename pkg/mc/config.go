@@ -0,0 +1,59 @@
+package mc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"sigs.k8s.io/yaml"
+)
+
+const configFile = "config.yaml"
+
+// config is the optional mc config file
+type config struct {
+	Contexts map[string]contextConfig `json:"contexts,omitempty"`
+	// Groups are named regexes that can be used instead of a regex in --regex and --negative-regex
+	Groups map[string]string `json:"groups,omitempty"`
+}
+
+// contextConfig holds the settings for a single context
+type contextConfig struct {
+	// Vars are custom variables available in templated kubectl args
+	Vars map[string]string `json:"vars,omitempty"`
+	// Priority orders the start of the contexts. Contexts with a higher priority are started first
+	Priority int `json:"priority,omitempty"`
+}
+
+// stateDir returns the directory mc keeps its config and state in
+func stateDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".kube", "mc")
+	}
+	return filepath.Join(home, ".kube", "mc")
+}
+
+// loadConfig reads the config file at path. A missing file results in an empty config
+func loadConfig(path string) (*config, error) {
+	c := &config{}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(b, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// expandGroup returns the regex of the group with the given name, or s itself if there is no such group
+func (c *config) expandGroup(s string) string {
+	if regex, ok := c.Groups[s]; ok {
+		return regex
+	}
+	return s
+}
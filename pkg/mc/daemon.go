@@ -0,0 +1,490 @@
+package mc
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+const (
+	daemonSocketFile = "daemon.sock"
+	// proxyServing is the prefix of the line kubectl proxy prints once it is ready
+	proxyServing = "Starting to serve on "
+)
+
+var (
+	// nonDelegatedVerbs are interactive or need the original kubeconfig, so they are never executed through the
+	// proxies of the daemon
+	nonDelegatedVerbs = map[string]bool{"exec": true, "attach": true, "port-forward": true, "cp": true, "proxy": true, "config": true}
+	// credentialEnv are the prefixes of the environment variables that select the kubeconfig, the credentials or the
+	// route to the clusters. Requests differing in any of them from the daemon are refused
+	credentialEnv = []string{
+		"KUBECONFIG=", "HOME=", "USERPROFILE=", "PATH=", "AWS_", "GOOGLE_", "CLOUDSDK_", "AZURE_", "ARM_", "OCI_",
+		"DIGITALOCEAN_", "HTTPS_PROXY=", "HTTP_PROXY=", "NO_PROXY=", "https_proxy=", "http_proxy=", "no_proxy=",
+	}
+
+	errDaemonRunning = fmt.Errorf("a daemon is already listening on the socket")
+	errDaemonProxies = fmt.Errorf("the daemon serves every context through a kubectl proxy on a localhost port, which accepts requests without authentication. Every local user and process can reach all clusters with your credentials through it. Start the daemon with --allow-local-proxies to accept this")
+)
+
+// daemonRequest is a kubectl execution the CLI delegates to the daemon. Dir and Env are the working directory and the
+// environment of the CLI, so relative paths and kubectl settings resolve as if kubectl was executed directly
+type daemonRequest struct {
+	Args      []string `json:"args"`
+	Context   string   `json:"context"`
+	Namespace string   `json:"namespace,omitempty"`
+	Dir       string   `json:"dir,omitempty"`
+	Env       []string `json:"env,omitempty"`
+}
+
+// daemonResponse is the result of a delegated kubectl execution. Refused is set if the daemon can't execute the
+// request, because the CLI uses another kubeconfig or other credentials than the daemon
+type daemonResponse struct {
+	Stdout   []byte `json:"stdout,omitempty"`
+	Stderr   []byte `json:"stderr,omitempty"`
+	ExitCode int    `json:"exitCode"`
+	Refused  bool   `json:"refused,omitempty"`
+}
+
+// proxy is a running `kubectl proxy` of a context, holding its authenticated connection to the API server
+type proxy struct {
+	sync.Mutex
+	cmd        *exec.Cmd
+	server     string
+	kubeconfig string
+	// namespace is the default namespace of the kubeconfig
+	namespace string
+	exited    bool
+}
+
+// daemon executes kubectl against the warm proxies of every context. The proxies are started on the first request
+// for their context, so kubectl doesn't have to authenticate and the discovery cache of the proxy address stays warm
+type daemon struct {
+	socket string
+	dir    string
+	// env is the environment the daemon was started with, which the proxies authenticate with
+	env []string
+	// namespaces are the default namespaces by context, loaded when the kubeconfig files were last modified at
+	// modified
+	namespaces map[string]string
+	modified   time.Time
+
+	mutex   sync.Mutex
+	proxies map[string]*proxy
+	// logger is captured on creation, as the daemon serves requests concurrently to everything else
+	logger *zap.Logger
+
+	// to allow dependency injection
+	startProxy     func(kubeContext string) (*exec.Cmd, string, error)
+	command        func(args []string, dir string, env []string) Cmd
+	loadNamespaces func() (map[string]string, error)
+}
+
+// daemonCmd is a Cmd executing kubectl through the daemon. If the daemon refuses the request, direct is executed
+// instead
+type daemonCmd struct {
+	socket  string
+	request daemonRequest
+	direct  Cmd
+}
+
+// newDaemonCmd returns the daemon command
+func (mc *MC) newDaemonCmd() *cobra.Command {
+	warm, allowProxies := false, false
+	cmd := &cobra.Command{
+		Use:   "daemon",
+		Short: "Run a daemon holding authenticated kubectl proxies per context, which mc delegates to while it is running",
+		Example: `
+# start the daemon in the background and start the proxies of all contexts right away
+mc daemon --allow-local-proxies --warm &
+
+# all following invocations are delegated to the daemon
+mc -- get pods`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logger, _ = zap.NewProduction()
+			if mc.Debug {
+				logger, _ = zap.NewDevelopment()
+			}
+			defer logger.Sync()
+			if !allowProxies {
+				return errDaemonProxies
+			}
+			d, err := newDaemon(mc.DaemonSocket, mc.getConfigViewCmd)
+			if err != nil {
+				return err
+			}
+			defer d.close()
+
+			l, err := d.listen()
+			if err != nil {
+				return err
+			}
+			if warm {
+				contexts, err := mc.runner(nil).listContexts(mc.getListContextsCmd())
+				if err != nil {
+					return err
+				}
+				d.warm(contexts)
+			}
+
+			stop := make(chan os.Signal, 1)
+			signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+			go func() {
+				<-stop
+				l.Close()
+			}()
+			fmt.Fprintf(cmd.ErrOrStderr(), "listening on %s\n", mc.DaemonSocket)
+			d.serve(l)
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&allowProxies, "allow-local-proxies", allowProxies, "accept that the kubectl proxies of the daemon listen on localhost ports without authentication, so every local user can reach the clusters with your credentials")
+	cmd.Flags().BoolVar(&warm, "warm", warm, "start the proxies of all contexts on startup instead of on their first use")
+	cmd.Flags().BoolVarP(&mc.Debug, "debug", "d", mc.Debug, "enable debug output")
+	return cmd
+}
+
+// newDaemon returns a daemon listening on socket, with the default namespaces of the contexts read with configView
+func newDaemon(socket string, configView func() Cmd) (*daemon, error) {
+	dir, err := ioutil.TempDir("", "kubectl-mc-daemon-")
+	if err != nil {
+		return nil, err
+	}
+	d := &daemon{
+		socket:     socket,
+		dir:        dir,
+		env:        os.Environ(),
+		proxies:    map[string]*proxy{},
+		logger:     logger,
+		startProxy: startProxy,
+	}
+	d.command = func(args []string, dir string, env []string) Cmd {
+		cmd := exec.Command("kubectl", args...)
+		cmd.Dir = dir
+		cmd.Env = env
+		return cmd
+	}
+	d.loadNamespaces = func() (map[string]string, error) {
+		k, err := loadKubeconfig(configView())
+		if err != nil {
+			return nil, err
+		}
+		namespaces := map[string]string{}
+		for _, c := range k.Contexts {
+			namespaces[c.Name] = c.Context.Namespace
+		}
+		return namespaces, nil
+	}
+	if _, err := d.namespace(""); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	return d, nil
+}
+
+// startProxy starts `kubectl proxy` on a random localhost port for a context and returns its address once it is ready.
+// kubectl can't use a proxy on a unix socket as server, so the port is reachable by every local user
+func startProxy(kubeContext string) (*exec.Cmd, string, error) {
+	cmd := exec.Command("kubectl", "proxy", "--context", kubeContext, "--address", "127.0.0.1", "--port", "0")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, "", err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, "", err
+	}
+	line, err := bufio.NewReader(stdout).ReadString('\n')
+	if !strings.HasPrefix(line, proxyServing) {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return nil, "", fmt.Errorf("couldn't start kubectl proxy for %s: %s %v", kubeContext, strings.TrimSpace(line), err)
+	}
+	return cmd, "http://" + strings.TrimSpace(strings.TrimPrefix(line, proxyServing)), nil
+}
+
+// listen listens on the socket of the daemon. A stale socket file of a daemon that isn't running anymore is removed
+func (d *daemon) listen() (net.Listener, error) {
+	if daemonRunning(d.socket) {
+		return nil, errDaemonRunning
+	}
+	os.Remove(d.socket)
+	if err := os.MkdirAll(filepath.Dir(d.socket), 0700); err != nil {
+		return nil, err
+	}
+	return net.Listen("unix", d.socket)
+}
+
+// serve handles every connection until l is closed
+func (d *daemon) serve(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go d.handle(conn)
+	}
+}
+
+// handle executes the request of a single connection and writes the response
+func (d *daemon) handle(conn net.Conn) {
+	defer conn.Close()
+	var req daemonRequest
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		d.logger.Debug("invalid request", zap.Error(err))
+		return
+	}
+	if err := json.NewEncoder(conn).Encode(d.execute(req)); err != nil {
+		d.logger.Debug("couldn't write response", zap.Error(err))
+	}
+}
+
+// execute runs kubectl for a request against the proxy of its context, in the working directory and with the
+// environment of the request. Requests using another kubeconfig or other credentials than the daemon are refused, as
+// their contexts can refer to other clusters or users than the proxies
+func (d *daemon) execute(req daemonRequest) daemonResponse {
+	if name := credentialEnvDiff(d.env, req.Env); name != "" {
+		d.logger.Debug("refusing request with other credentials", zap.String("variable", name))
+		return daemonResponse{Refused: true}
+	}
+	kubeconfig, err := d.proxy(req.Context)
+	if err != nil {
+		return daemonResponse{Stderr: []byte(err.Error()), ExitCode: -1}
+	}
+	args := append([]string{"--kubeconfig", kubeconfig}, getLocalArgs(req.Args, req.Context, req.Namespace)...)
+	d.logger.Debug("executing", zap.Strings("args", args))
+	stdout, err := d.command(args, req.Dir, req.Env).Output()
+	res := daemonResponse{Stdout: stdout}
+	if err != nil {
+		res.Stderr, res.ExitCode = stderr(err), exitCode(err)
+		if res.Stderr == nil {
+			res.Stderr = []byte(err.Error())
+		}
+	}
+	return res
+}
+
+// proxy returns the kubeconfig pointing to the proxy of a context, starting the proxy if it isn't running
+func (d *daemon) proxy(kubeContext string) (string, error) {
+	d.mutex.Lock()
+	p, ok := d.proxies[kubeContext]
+	if !ok {
+		p = &proxy{}
+		d.proxies[kubeContext] = p
+	}
+	d.mutex.Unlock()
+
+	namespace, err := d.namespace(kubeContext)
+	if err != nil {
+		return "", err
+	}
+
+	p.Lock()
+	defer p.Unlock()
+	if p.cmd == nil || p.exited {
+		d.logger.Debug("starting proxy", zap.String("context", kubeContext))
+		cmd, server, err := d.startProxy(kubeContext)
+		if err != nil {
+			return "", err
+		}
+		p.cmd, p.server, p.kubeconfig, p.exited = cmd, server, "", false
+		if cmd.Process != nil {
+			go func() {
+				cmd.Wait()
+				p.Lock()
+				p.exited = true
+				p.Unlock()
+			}()
+		}
+	}
+	if p.kubeconfig == "" || p.namespace != namespace {
+		if p.kubeconfig, err = d.writeKubeconfig(kubeContext, p.server, namespace); err != nil {
+			return "", err
+		}
+		p.namespace = namespace
+	}
+	return p.kubeconfig, nil
+}
+
+// namespace returns the default namespace of a context. The namespaces are loaded again whenever a kubeconfig file
+// was modified, so changes like `kubectl config set-context --current --namespace` apply to the next request
+func (d *daemon) namespace(kubeContext string) (string, error) {
+	var modified time.Time
+	for _, path := range kubeconfigFiles(d.env) {
+		if fi, err := os.Stat(path); err == nil && fi.ModTime().After(modified) {
+			modified = fi.ModTime()
+		}
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.namespaces == nil || !modified.Equal(d.modified) {
+		namespaces, err := d.loadNamespaces()
+		if err != nil {
+			return "", err
+		}
+		d.namespaces, d.modified = namespaces, modified
+	}
+	return d.namespaces[kubeContext], nil
+}
+
+// writeKubeconfig writes a kubeconfig for a context with the proxy as server. It doesn't contain any credentials,
+// as the proxy authenticates all requests
+func (d *daemon) writeKubeconfig(kubeContext string, server string, namespace string) (string, error) {
+	k := map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Config",
+		"clusters": []interface{}{map[string]interface{}{
+			"name":    kubeContext,
+			"cluster": map[string]interface{}{"server": server},
+		}},
+		"contexts": []interface{}{map[string]interface{}{
+			"name":    kubeContext,
+			"context": map[string]interface{}{"cluster": kubeContext, "namespace": namespace},
+		}},
+		"current-context": kubeContext,
+	}
+	b, err := json.Marshal(k)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(d.dir, fmt.Sprintf("%d.json", time.Now().UnixNano()))
+	return path, ioutil.WriteFile(path, b, 0600)
+}
+
+// warm starts the proxies of all contexts in parallel
+func (d *daemon) warm(contexts []string) {
+	var wg sync.WaitGroup
+	for _, c := range contexts {
+		wg.Add(1)
+		go func(c string) {
+			defer wg.Done()
+			if _, err := d.proxy(c); err != nil {
+				d.logger.Warn("couldn't start proxy", zap.String("context", c), zap.Error(err))
+			}
+		}(c)
+	}
+	wg.Wait()
+}
+
+// close stops all proxies and removes the socket and the kubeconfigs of the proxies
+func (d *daemon) close() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	for _, p := range d.proxies {
+		if p.cmd != nil && p.cmd.Process != nil {
+			p.cmd.Process.Kill()
+		}
+	}
+	os.Remove(d.socket)
+	os.RemoveAll(d.dir)
+}
+
+// daemonRunning returns true if a daemon is listening on socket
+func daemonRunning(socket string) bool {
+	conn, err := net.DialTimeout("unix", socket, 100*time.Millisecond)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// delegatable returns true if args can be executed through the proxies of the daemon
+func delegatable(args []string) bool {
+	for _, arg := range args {
+		if arg == "--kubeconfig" || strings.HasPrefix(arg, "--kubeconfig=") {
+			return false
+		}
+	}
+	return !nonDelegatedVerbs[kubectlVerb(args)]
+}
+
+// Output sends the request to the daemon and returns the stdout of the execution
+func (c *daemonCmd) Output() ([]byte, error) {
+	conn, err := net.Dial("unix", c.socket)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err := json.NewEncoder(conn).Encode(c.request); err != nil {
+		return nil, err
+	}
+	var res daemonResponse
+	if err := json.NewDecoder(conn).Decode(&res); err != nil {
+		return nil, fmt.Errorf("couldn't read the response of the daemon: %v", err)
+	}
+	if res.Refused && c.direct != nil {
+		return c.direct.Output()
+	}
+	if res.ExitCode != 0 {
+		return res.Stdout, &exitError{Stderr: res.Stderr, Code: res.ExitCode}
+	}
+	return res.Stdout, nil
+}
+
+// newDaemonRequest returns the request to execute args against a context and namespace with the working directory and
+// the environment of the CLI
+func newDaemonRequest(args []string, kubeContext string, namespace string) daemonRequest {
+	dir, _ := os.Getwd()
+	return daemonRequest{Args: args, Context: kubeContext, Namespace: namespace, Dir: dir, Env: os.Environ()}
+}
+
+// credentialEnvDiff returns the name of the first credential variable whose value differs between a and b, or an
+// empty string if they use the same credentials
+func credentialEnvDiff(a []string, b []string) string {
+	for _, env := range [][]string{a, b} {
+		for _, e := range env {
+			name := strings.SplitN(e, "=", 2)[0]
+			if isCredentialEnv(e) && envValue(a, name) != envValue(b, name) {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// isCredentialEnv returns true if e is one of the credentialEnv variables
+func isCredentialEnv(e string) bool {
+	for _, prefix := range credentialEnv {
+		if strings.HasPrefix(e, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// kubeconfigFiles returns the kubeconfig files kubectl reads with env
+func kubeconfigFiles(env []string) []string {
+	if k := envValue(env, "KUBECONFIG"); k != "" {
+		return filepath.SplitList(k)
+	}
+	home := envValue(env, "HOME")
+	if home == "" {
+		home = envValue(env, "USERPROFILE")
+	}
+	return []string{filepath.Join(home, ".kube", "config")}
+}
+
+// envValue returns the value of the variable name in env
+func envValue(env []string, name string) string {
+	for _, e := range env {
+		if strings.HasPrefix(e, name+"=") {
+			return strings.TrimPrefix(e, name+"=")
+		}
+	}
+	return ""
+}
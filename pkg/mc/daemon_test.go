@@ -0,0 +1,193 @@
+package mc
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jonnylangefeld/kubectl-mc/pkg/mc/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+// startTestDaemon starts a daemon on a temp socket, which executes every request with m
+func startTestDaemon(t *testing.T, m Cmd) (*daemon, *[][]string) {
+	ctrl := gomock.NewController(t)
+	configView := mocks.NewMockCmd(ctrl)
+	configView.EXPECT().Output().Return([]byte(`{"contexts":[{"name":"kind-kind","context":{"cluster":"kind-kind","namespace":"default"}}]}`), nil)
+	d, err := newDaemon(filepath.Join(t.TempDir(), daemonSocketFile), func() Cmd {
+		return configView
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{kubeContext: namespace}, d.namespaces)
+	d.startProxy = func(kubeContext string) (*exec.Cmd, string, error) {
+		return &exec.Cmd{}, "http://127.0.0.1:8001", nil
+	}
+	var mutex sync.Mutex
+	var calls [][]string
+	d.command = func(args []string, dir string, env []string) Cmd {
+		mutex.Lock()
+		defer mutex.Unlock()
+		calls = append(calls, append([]string{dir}, args...))
+		return m
+	}
+	l, err := d.listen()
+	assert.NoError(t, err)
+	go d.serve(l)
+	t.Cleanup(func() {
+		l.Close()
+		d.close()
+	})
+	return d, &calls
+}
+
+func TestDaemon(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	m := mocks.NewMockCmd(ctrl)
+	m.EXPECT().Output().Return(kubectlReturn, nil)
+	m.EXPECT().Output().Return(nil, &exec.ExitError{Stderr: []byte("Error: forbidden")})
+	direct := mocks.NewMockCmd(ctrl)
+	direct.EXPECT().Output().Return([]byte("direct output\n"), nil).Times(2)
+
+	d, calls := startTestDaemon(t, m)
+	assert.True(t, daemonRunning(d.socket))
+	_, err := d.listen()
+	assert.Equal(t, errDaemonRunning, err)
+
+	got, err := (&daemonCmd{socket: d.socket, request: daemonRequest{Args: []string{"get", "pods"}, Context: kubeContext, Dir: "/tmp/manifests", Env: os.Environ()}}).Output()
+	assert.NoError(t, err)
+	assert.Equal(t, kubectlReturn, got)
+
+	got, err = kubectl(&daemonCmd{socket: d.socket, request: daemonRequest{Args: []string{"get", "pods"}, Context: kubeContext, Namespace: "kube-system", Env: os.Environ()}})
+	assert.Nil(t, got)
+	assert.EqualError(t, err, "forbidden")
+
+	// another kubeconfig or other credentials than the ones of the daemon are executed directly
+	for _, env := range []string{"KUBECONFIG=/tmp/other", "AWS_PROFILE=other"} {
+		got, err = (&daemonCmd{socket: d.socket, request: daemonRequest{Args: []string{"get", "pods"}, Context: kubeContext, Env: append([]string{env}, os.Environ()...)}, direct: direct}).Output()
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("direct output\n"), got)
+	}
+
+	// the proxy is only started once and its kubeconfig is used for every request
+	assert.Len(t, d.proxies, 1)
+	kubeconfig := d.proxies[kubeContext].kubeconfig
+	assert.Equal(t, [][]string{
+		{"/tmp/manifests", "--kubeconfig", kubeconfig, "get", "pods", "--context", kubeContext},
+		{"", "--kubeconfig", kubeconfig, "get", "pods", "--context", kubeContext, "--namespace", "kube-system"},
+	}, *calls)
+	b, err := ioutil.ReadFile(kubeconfig)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+  "apiVersion": "v1",
+  "kind": "Config",
+  "clusters": [{"name": "kind-kind", "cluster": {"server": "http://127.0.0.1:8001"}}],
+  "contexts": [{"name": "kind-kind", "context": {"cluster": "kind-kind", "namespace": "default"}}],
+  "current-context": "kind-kind"
+}`, string(b))
+}
+
+func TestDelegatable(t *testing.T) {
+	assert.True(t, delegatable([]string{"get", "pods"}))
+	assert.True(t, delegatable([]string{"-v", "6", "apply", "-f", "x.yaml"}))
+	assert.False(t, delegatable([]string{"exec", "pod", "--", "ls"}))
+	assert.False(t, delegatable([]string{"config", "view"}))
+	assert.False(t, delegatable([]string{"--kubeconfig", "/tmp/x", "get", "pods"}))
+}
+
+func TestMC_DelegatesToDaemon(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	list := mocks.NewMockCmd(ctrl)
+	m := mocks.NewMockCmd(ctrl)
+	direct := mocks.NewMockCmd(ctrl)
+	list.EXPECT().Output().Return([]byte("kind-kind\n"), nil).Times(3)
+	m.EXPECT().Output().Return(kubectlReturn, nil)
+	direct.EXPECT().Output().Return([]byte("exec output\n"), nil).Times(2)
+
+	d, _ := startTestDaemon(t, m)
+	execute := func(args ...string) string {
+		mc := New("")
+		mc.getListContextsCmd = func() Cmd {
+			return list
+		}
+		mc.getKubectlCmd = func(ctx context.Context, args []string, c string, namespace string) Cmd {
+			return direct
+		}
+		b := bytes.NewBuffer([]byte(``))
+		mc.Cmd.SetOut(b)
+		mc.Cmd.SetArgs(append([]string{"--daemon-socket", d.socket}, args...))
+		assert.NoError(t, mc.Cmd.Execute())
+		return b.String()
+	}
+
+	assert.Equal(t, formatContext(kubeContext, "", kubectlReturn), execute("--", "get", "pods"))
+	assert.Equal(t, formatContext(kubeContext, "", []byte("exec output\n")), execute("--", "exec", "pod", "--", "ls"))
+	assert.Equal(t, formatContext(kubeContext, "", []byte("exec output\n")), execute("--no-daemon", "--", "get", "pods"))
+}
+
+func TestDaemon_ReloadsNamespaces(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	d, _ := startTestDaemon(t, mocks.NewMockCmd(ctrl))
+	path := filepath.Join(t.TempDir(), "config")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{}`), 0600))
+	d.env = []string{"KUBECONFIG=" + path}
+	ns := "default"
+	d.loadNamespaces = func() (map[string]string, error) {
+		return map[string]string{kubeContext: ns}, nil
+	}
+
+	kubeconfig, err := d.proxy(kubeContext)
+	assert.NoError(t, err)
+	b, err := ioutil.ReadFile(kubeconfig)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `"namespace":"default"`)
+
+	// the namespace is only loaded again once the kubeconfig file was modified
+	ns = "kube-system"
+	kubeconfig, err = d.proxy(kubeContext)
+	assert.NoError(t, err)
+	b, err = ioutil.ReadFile(kubeconfig)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `"namespace":"default"`)
+
+	assert.NoError(t, os.Chtimes(path, time.Now().Add(time.Minute), time.Now().Add(time.Minute)))
+	kubeconfig, err = d.proxy(kubeContext)
+	assert.NoError(t, err)
+	b, err = ioutil.ReadFile(kubeconfig)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `"namespace":"kube-system"`)
+	assert.Len(t, d.proxies, 1)
+}
+
+func TestNewDaemonRequest(t *testing.T) {
+	t.Setenv("KUBECONFIG", "/tmp/kubeconfig")
+	dir, err := os.Getwd()
+	assert.NoError(t, err)
+
+	got := newDaemonRequest([]string{"apply", "-f", "manifests/"}, kubeContext, namespace)
+	assert.Equal(t, dir, got.Dir)
+	assert.Equal(t, os.Environ(), got.Env)
+	assert.Equal(t, "/tmp/kubeconfig", envValue(got.Env, "KUBECONFIG"))
+}
+
+func TestCredentialEnvDiff(t *testing.T) {
+	env := []string{"KUBECONFIG=/tmp/kubeconfig", "AWS_PROFILE=prod", "TERM=xterm"}
+	assert.Equal(t, "", credentialEnvDiff(env, []string{"TERM=screen", "AWS_PROFILE=prod", "KUBECONFIG=/tmp/kubeconfig"}))
+	assert.Equal(t, "AWS_PROFILE", credentialEnvDiff(env, []string{"KUBECONFIG=/tmp/kubeconfig", "AWS_PROFILE=dev"}))
+	assert.Equal(t, "AWS_PROFILE", credentialEnvDiff(env, []string{"KUBECONFIG=/tmp/kubeconfig"}))
+	assert.Equal(t, "GOOGLE_APPLICATION_CREDENTIALS", credentialEnvDiff(env, append([]string{"GOOGLE_APPLICATION_CREDENTIALS=/tmp/key.json"}, env...)))
+}
+
+func TestDaemonRequiresAllowLocalProxies(t *testing.T) {
+	mc := New("")
+	mc.Cmd.SetOut(ioutil.Discard)
+	mc.Cmd.SetErr(ioutil.Discard)
+	mc.Cmd.SetArgs([]string{"daemon", "--daemon-socket", filepath.Join(t.TempDir(), daemonSocketFile)})
+	assert.Equal(t, errDaemonProxies, mc.Cmd.Execute())
+}
@@ -0,0 +1,87 @@
+package mc
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+	"time"
+)
+
+const (
+	durationsFile = "durations.json"
+	// durationWeight is the weight of the latest duration in the moving average of a context
+	durationWeight = 0.5
+)
+
+// readDurations reads the average kubectl duration by context from path. A missing file has no durations
+func readDurations(path string) (map[string]time.Duration, error) {
+	durations := map[string]time.Duration{}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return durations, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &durations); err != nil {
+		return nil, err
+	}
+	return durations, nil
+}
+
+// prioritize orders contexts by their priority in the config file and then by their average duration, so the
+// highest priority and historically slowest contexts are started first. The order of equal contexts is kept
+func (mc *MC) prioritize(contexts []string) []string {
+	durations := map[string]time.Duration{}
+	if mc.DurationsFile != "" {
+		var err error
+		if durations, err = readDurations(mc.DurationsFile); err != nil {
+			logger.Debug("couldn't read durations, keeping the order of the kubeconfig")
+		}
+	}
+	priority := func(c string) int {
+		if mc.config == nil {
+			return 0
+		}
+		return mc.config.Contexts[c].Priority
+	}
+
+	ordered := append([]string{}, contexts...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if pi, pj := priority(ordered[i]), priority(ordered[j]); pi != pj {
+			return pi > pj
+		}
+		return durations[ordered[i]] > durations[ordered[j]]
+	})
+	return ordered
+}
+
+// saveDurations updates the moving average duration of every context with the longest execution of the results
+func (mc *MC) saveDurations(results []Result) error {
+	durations, err := readDurations(mc.DurationsFile)
+	if err != nil {
+		return err
+	}
+	latest := map[string]time.Duration{}
+	for _, r := range results {
+		// cached results didn't take as long as the context would have
+		if r.Age > 0 {
+			continue
+		}
+		if r.Duration > latest[r.Context] {
+			latest[r.Context] = r.Duration
+		}
+	}
+	for c, d := range latest {
+		if avg, ok := durations[c]; ok {
+			d = time.Duration(durationWeight*float64(d) + (1-durationWeight)*float64(avg))
+		}
+		durations[c] = d
+	}
+	b, err := json.MarshalIndent(durations, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(mc.DurationsFile, b)
+}
@@ -0,0 +1,72 @@
+package mc
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jonnylangefeld/kubectl-mc/pkg/mc/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMC_Prioritize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), durationsFile)
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"b": 3000000000, "c": 1000000000, "d": 5000000000}`), 0644))
+
+	mc := &MC{DurationsFile: path, config: &config{Contexts: map[string]contextConfig{"e": {Priority: 1}}}}
+	contexts := []string{"a", "b", "c", "d", "e", "f"}
+	assert.Equal(t, []string{"e", "d", "b", "c", "a", "f"}, mc.prioritize(contexts))
+	assert.Equal(t, []string{"a", "b", "c", "d", "e", "f"}, contexts)
+
+	mc = &MC{}
+	assert.Equal(t, contexts, mc.prioritize(contexts))
+}
+
+func TestMC_SaveDurations(t *testing.T) {
+	mc := &MC{DurationsFile: filepath.Join(t.TempDir(), "mc", durationsFile)}
+	assert.NoError(t, mc.saveDurations([]Result{
+		{Context: "a", Namespace: "x", Duration: time.Second},
+		{Context: "a", Namespace: "y", Duration: 3 * time.Second},
+	}))
+	got, err := readDurations(mc.DurationsFile)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]time.Duration{"a": 3 * time.Second}, got)
+
+	assert.NoError(t, mc.saveDurations([]Result{{Context: "a", Duration: time.Second}, {Context: "b", Duration: time.Second}, {Context: "c", Duration: time.Millisecond, Age: time.Minute}}))
+	got, err = readDurations(mc.DurationsFile)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]time.Duration{"a": 2 * time.Second, "b": time.Second}, got)
+}
+
+func TestMC_DurationAwareScheduling(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	list := mocks.NewMockCmd(ctrl)
+	m := mocks.NewMockCmd(ctrl)
+	path := filepath.Join(t.TempDir(), durationsFile)
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"kind-kind2": 60000000000}`), 0644))
+
+	list.EXPECT().Output().Return([]byte("kind-kind\nkind-kind1\nkind-kind2\n"), nil)
+	m.EXPECT().Output().Return([]byte("ok\n"), nil).Times(3)
+
+	mc := New("")
+	mc.getListContextsCmd = func() Cmd {
+		return list
+	}
+	var order []string
+	mc.getKubectlCmd = func(ctx context.Context, args []string, c string, namespace string) Cmd {
+		order = append(order, c)
+		return m
+	}
+	mc.Cmd.SetOut(ioutil.Discard)
+	mc.Cmd.SetArgs([]string{"-p", "1", "--durations-file", path, "--", "get", "pods"})
+	assert.NoError(t, mc.Cmd.Execute())
+	assert.Equal(t, []string{"kind-kind2", "kind-kind", "kind-kind1"}, order)
+
+	got, err := readDurations(path)
+	assert.NoError(t, err)
+	assert.Len(t, got, 3)
+	assert.True(t, got["kind-kind2"] < time.Minute)
+}
@@ -0,0 +1,143 @@
+package mc
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// errorClass is a known cause of failed kubectl executions
+type errorClass struct {
+	name    string
+	pattern *regexp.Regexp
+	hint    string
+}
+
+// errorGroup holds all contexts that failed with the same normalized error
+type errorGroup struct {
+	message string
+	keys    []string
+}
+
+var (
+	// errorClasses are evaluated in order, the first matching class wins
+	errorClasses = []errorClass{
+		{
+			name:    "auth expired",
+			pattern: regexp.MustCompile(`(?i)expired|unauthorized|must be logged in|provide credentials|getting credentials|refresh token|invalid_grant`),
+			hint:    "re-authenticate with the cloud CLI of the cluster, like `aws sso login`, `gcloud auth login` or `az login`",
+		},
+		{
+			name:    "forbidden",
+			pattern: regexp.MustCompile(`(?i)forbidden`),
+			hint:    "the user of the context lacks the RBAC permissions. Check them with `kubectl auth can-i`",
+		},
+		{
+			name:    "dns",
+			pattern: regexp.MustCompile(`(?i)no such host|dial tcp: lookup|server misbehaving`),
+			hint:    "the API server name can't be resolved. Check your VPN and DNS settings and the server in the kubeconfig",
+		},
+		{
+			name:    "connection refused",
+			pattern: regexp.MustCompile(`(?i)connection refused`),
+			hint:    "nothing listens on the API server address. Check that the cluster is running and the server in the kubeconfig",
+		},
+		{
+			name:    "timeout",
+			pattern: regexp.MustCompile(`(?i)timeout|timed out|deadline exceeded`),
+			hint:    "the API server doesn't respond in time. Check your VPN, firewalls and network connectivity",
+		},
+		{
+			name:    "not found",
+			pattern: regexp.MustCompile(`(?i)notfound|not found|doesn't have a resource type`),
+			hint:    "the resource doesn't exist in these contexts. Check the name, the namespace and the API versions of the clusters",
+		},
+	}
+	otherErrors = errorClass{name: "other"}
+
+	// errorNormalizations replace the parts of error messages that differ between clusters
+	errorNormalizations = []struct {
+		pattern     *regexp.Regexp
+		replacement string
+	}{
+		{regexp.MustCompile(`https?://[^\s"]+`), "<url>"},
+		{regexp.MustCompile(`(?i)\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`), "<id>"},
+		{regexp.MustCompile(`(?i)\b[0-9a-f]{16,}\b`), "<id>"},
+		{regexp.MustCompile(`\b\d{1,3}(\.\d{1,3}){3}(:\d+)?\b`), "<ip>"},
+		{regexp.MustCompile(`\[[0-9a-fA-F:]*:[0-9a-fA-F:]+\](:\d+)?`), "<ip>"},
+		{regexp.MustCompile(`(?i)\b[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)+\.[a-z]{2,}(:\d+)?\b`), "<host>"},
+	}
+)
+
+// classifyError returns the class of an error message
+func classifyError(msg string) errorClass {
+	for _, c := range errorClasses {
+		if c.pattern.MatchString(msg) {
+			return c
+		}
+	}
+	return otherErrors
+}
+
+// normalizeError strips hostnames, IPs, URLs and request IDs from the first line of an error message, so the same
+// error of different clusters results in the same message
+func normalizeError(msg string) string {
+	msg = firstLine(msg)
+	for _, n := range errorNormalizations {
+		msg = n.pattern.ReplaceAllString(msg, n.replacement)
+	}
+	return msg
+}
+
+// writeErrorSummary groups all failed results by error class and normalized message and writes every class with
+// its contexts and a remediation hint
+func writeErrorSummary(out io.Writer, results []Result) {
+	classes := map[string][]*errorGroup{}
+	failed := 0
+	for _, r := range results {
+		if r.Err == nil {
+			continue
+		}
+		failed++
+		c := classifyError(r.Err.Error())
+		msg := normalizeError(r.Err.Error())
+		var group *errorGroup
+		for _, g := range classes[c.name] {
+			if g.message == msg {
+				group = g
+			}
+		}
+		if group == nil {
+			group = &errorGroup{message: msg}
+			classes[c.name] = append(classes[c.name], group)
+		}
+		group.keys = append(group.keys, r.key())
+	}
+	if failed == 0 {
+		return
+	}
+
+	fmt.Fprintf(out, "\n%d of %d executions failed\n", failed, len(results))
+	for _, c := range append(errorClasses, otherErrors) {
+		groups := classes[c.name]
+		if len(groups) == 0 {
+			continue
+		}
+		sort.SliceStable(groups, func(i, j int) bool {
+			return len(groups[i].keys) > len(groups[j].keys)
+		})
+		n := 0
+		for _, g := range groups {
+			n += len(g.keys)
+		}
+		fmt.Fprintf(out, "\n%s (%d)\n", c.name, n)
+		for _, g := range groups {
+			fmt.Fprintf(out, "  %s\n    %s\n", g.message, strings.Join(g.keys, ", "))
+		}
+		if c.hint != "" {
+			fmt.Fprintf(out, "  hint: %s\n", c.hint)
+		}
+	}
+}
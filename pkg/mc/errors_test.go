@@ -0,0 +1,116 @@
+package mc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jonnylangefeld/kubectl-mc/pkg/mc/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := map[string]string{
+		"getting credentials: exec: executable aws failed with exit code 255":                                 "auth expired",
+		"You must be logged in to the server (Unauthorized)":                                                  "auth expired",
+		`pods is forbidden: User "jonny" cannot list resource "pods" in API group "" in the namespace "x"`:    "forbidden",
+		"Unable to connect to the server: dial tcp: lookup api.prod.example.com on 10.0.0.2:53: no such host": "dns",
+		"The connection to the server 10.0.0.1:6443 was refused - did you specify the right host or port?":    "other",
+		"Unable to connect to the server: dial tcp 10.0.0.1:443: connect: connection refused":                 "connection refused",
+		"Unable to connect to the server: dial tcp 10.0.0.1:443: i/o timeout":                                 "timeout",
+		`Error from server (NotFound): deployments.apps "app" not found`:                                      "not found",
+		"something else": "other",
+	}
+	for msg, want := range tests {
+		t.Run(want, func(t *testing.T) {
+			assert.Equal(t, want, classifyError(msg).name)
+		})
+	}
+}
+
+func TestNormalizeError(t *testing.T) {
+	tests := map[string]string{
+		"Unable to connect to the server: dial tcp 10.0.0.1:443: i/o timeout\nmore":                           "Unable to connect to the server: dial tcp <ip>: i/o timeout",
+		"Unable to connect to the server: dial tcp: lookup api.prod.example.com on 10.0.0.2:53: no such host": "Unable to connect to the server: dial tcp: lookup <host> on <ip>: no such host",
+		`Get "https://abc.eks.amazonaws.com/api?timeout=32s": dial tcp [2001:db8::1]:443: connect: refused`:   `Get "<url>": dial tcp <ip>: connect: refused`,
+		"an error on the server (request id 4a1b2c3d-1234-5678-9abc-def012345678) has prevented the request":  "an error on the server (request id <id>) has prevented the request",
+		"error from server: trace 0123456789abcdef0123 failed":                                                "error from server: trace <id> failed",
+		`Error from server (NotFound): deployments.apps "app" not found`:                                      `Error from server (NotFound): deployments.apps "app" not found`,
+	}
+	for msg, want := range tests {
+		assert.Equal(t, want, normalizeError(msg))
+	}
+}
+
+func TestWriteErrorSummary(t *testing.T) {
+	results := []Result{
+		{Context: "a", Err: fmt.Errorf("dial tcp 10.0.0.1:443: connect: connection refused")},
+		{Context: "b", Stdout: kubectlReturn},
+		{Context: "c", Err: fmt.Errorf("dial tcp 10.0.0.2:443: connect: connection refused")},
+		{Context: "d", Namespace: "x", Err: fmt.Errorf("You must be logged in to the server (Unauthorized)")},
+		{Context: "e", Err: fmt.Errorf("dial tcp 10.0.0.3:6443: i/o timeout")},
+		{Context: "f", Err: fmt.Errorf("oops")},
+		{Context: "g", Err: fmt.Errorf("getting credentials: exec: executable aws failed with exit code 255")},
+	}
+	b := bytes.NewBuffer([]byte(``))
+	writeErrorSummary(b, results)
+	assert.Equal(t, `
+6 of 7 executions failed
+
+auth expired (2)
+  You must be logged in to the server (Unauthorized)
+    d: x
+  getting credentials: exec: executable aws failed with exit code 255
+    g
+  hint: re-authenticate with the cloud CLI of the cluster, like `+"`aws sso login`, `gcloud auth login` or `az login`"+`
+
+connection refused (2)
+  dial tcp <ip>: connect: connection refused
+    a, c
+  hint: nothing listens on the API server address. Check that the cluster is running and the server in the kubeconfig
+
+timeout (1)
+  dial tcp <ip>: i/o timeout
+    e
+  hint: the API server doesn't respond in time. Check your VPN, firewalls and network connectivity
+
+other (1)
+  oops
+    f
+`, b.String())
+
+	b.Reset()
+	writeErrorSummary(b, results[1:2])
+	assert.Empty(t, b.String())
+}
+
+func TestMC_SummarizeErrors(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	list := mocks.NewMockCmd(ctrl)
+	succeeded := mocks.NewMockCmd(ctrl)
+	failed := mocks.NewMockCmd(ctrl)
+	list.EXPECT().Output().Return([]byte("kind-kind\nkind-kind1\n"), nil)
+	succeeded.EXPECT().Output().Return(kubectlReturn, nil)
+	failed.EXPECT().Output().Return(nil, fmt.Errorf("dial tcp 127.0.0.1:6443: connect: connection refused"))
+
+	mc := New("")
+	mc.getListContextsCmd = func() Cmd {
+		return list
+	}
+	mc.getKubectlCmd = func(ctx context.Context, args []string, c string, namespace string) Cmd {
+		if c == kubeContext {
+			return succeeded
+		}
+		return failed
+	}
+	out := bytes.NewBuffer([]byte(``))
+	errOut := bytes.NewBuffer([]byte(``))
+	mc.Cmd.SetOut(out)
+	mc.Cmd.SetErr(errOut)
+	mc.Cmd.SetArgs([]string{"--summarize-errors", "--", "get", "pods"})
+	assert.NoError(t, mc.Cmd.Execute())
+	assert.Equal(t, formatContext(kubeContext, "", kubectlReturn), out.String())
+	assert.Contains(t, errOut.String(), "1 of 2 executions failed\n\nconnection refused (1)\n  dial tcp <ip>: connect: connection refused\n    kind-kind1\n")
+}
@@ -0,0 +1,88 @@
+package mc
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+var errGroupIdenticalUnsupported = fmt.Errorf("--group-identical can't be combined with csv or tsv output, --output-dir or --watch")
+
+// resultGroup is a set of results with identical output
+type resultGroup struct {
+	// keys are the keys of all results of the group
+	keys []string
+	// result is the first result of the group, carrying the output of all of them
+	result Result
+}
+
+// header returns the header of a group, listing all contexts and namespaces and their count
+func (g resultGroup) header() string {
+	return fmt.Sprintf("%s (%d)", strings.Join(g.keys, ", "), len(g.keys))
+}
+
+// groupIdentical groups results by the hash of their output. Failed results are only grouped with failed results.
+// The groups are ordered by their first result
+func groupIdentical(results []Result) (groups []resultGroup) {
+	index := map[[sha256.Size]byte]int{}
+	for _, r := range results {
+		h := sha256.New()
+		if r.Err != nil {
+			fmt.Fprintf(h, "error: %s", r.Err)
+		} else {
+			h.Write(r.Stdout)
+		}
+		var sum [sha256.Size]byte
+		copy(sum[:], h.Sum(nil))
+
+		if i, ok := index[sum]; ok {
+			groups[i].keys = append(groups[i].keys, r.key())
+			continue
+		}
+		index[sum] = len(groups)
+		groups = append(groups, resultGroup{keys: []string{r.key()}, result: r})
+	}
+	return
+}
+
+// printGroups prints the output of every group once, under a header listing all contexts that produced it
+func (mc *MC) printGroups(groups []resultGroup) {
+	for _, g := range groups {
+		if mc.ErrSummary && g.result.Err != nil {
+			continue
+		}
+		stdout := g.result.Stdout
+		if g.result.Err != nil {
+			stdout = []byte(g.result.Err.Error())
+		}
+		fmt.Fprint(mc.Cmd.OutOrStdout(), mc.formatText(Result{Context: g.header(), Err: g.result.Err}, string(stdout)))
+	}
+}
+
+// isEmpty returns true for successful results without any output, with the `No resources found` message of kubectl or
+// with an empty json list
+func isEmpty(r Result) bool {
+	if r.Err != nil {
+		return false
+	}
+	stdout := bytes.TrimSpace(r.Stdout)
+	if len(stdout) == 0 || bytes.HasPrefix(stdout, []byte("No resources found")) {
+		return true
+	}
+	var list struct {
+		Items *[]json.RawMessage `json:"items"`
+	}
+	return json.Unmarshal(stdout, &list) == nil && list.Items != nil && len(*list.Items) == 0
+}
+
+// withoutEmpty returns all results that aren't empty
+func withoutEmpty(results []Result) (filtered []Result) {
+	for _, r := range results {
+		if !isEmpty(r) {
+			filtered = append(filtered, r)
+		}
+	}
+	return
+}
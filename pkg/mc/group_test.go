@@ -0,0 +1,116 @@
+package mc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jonnylangefeld/kubectl-mc/pkg/mc/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroupIdentical(t *testing.T) {
+	results := []Result{
+		{Context: "a", Stdout: []byte("x")},
+		{Context: "b", Err: fmt.Errorf("x")},
+		{Context: "c", Stdout: []byte("y")},
+		{Context: "d", Stdout: []byte("x")},
+		{Context: "e", Err: fmt.Errorf("x")},
+	}
+	got := groupIdentical(results)
+	assert.Equal(t, []resultGroup{
+		{keys: []string{"a", "d"}, result: results[0]},
+		{keys: []string{"b", "e"}, result: results[1]},
+		{keys: []string{"c"}, result: results[2]},
+	}, got)
+	assert.Equal(t, "a, d (2)", got[0].header())
+}
+
+func TestIsEmpty(t *testing.T) {
+	tests := map[string]struct {
+		result Result
+		want   bool
+	}{
+		"empty":              {result: Result{}, want: true},
+		"whitespace":         {result: Result{Stdout: []byte("\n ")}, want: true},
+		"no resources found": {result: Result{Stdout: []byte("No resources found in default namespace.\n")}, want: true},
+		"empty list":         {result: Result{Stdout: []byte(`{"apiVersion":"v1","items":[],"kind":"List"}`)}, want: true},
+		"list":               {result: Result{Stdout: kubectlReturnSA}},
+		"object":             {result: Result{Stdout: []byte(`{"kind":"Pod"}`)}},
+		"table":              {result: Result{Stdout: kubectlReturn}},
+		"failed":             {result: Result{Err: fmt.Errorf("forbidden")}},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.want, isEmpty(test.result))
+		})
+	}
+}
+
+func TestMC_GroupIdenticalAndHideEmpty(t *testing.T) {
+	tests := map[string]struct {
+		args []string
+		want string
+	}{
+		"group identical": {
+			args: []string{"--group-identical", "--", "get", "pods"},
+			want: "\nkind-kind, kind-kind2 (2)\n-------------------------\nNAME   READY\npod-a  1/1\n" +
+				"\nkind-kind1, kind-kind3 (2)\n--------------------------\n" +
+				"\nkind-kind4 (1)\n--------------\nforbidden",
+		},
+		"hide empty": {
+			args: []string{"--hide-empty", "--", "get", "pods"},
+			want: "\nkind-kind\n---------\nNAME   READY\npod-a  1/1\n" +
+				"\nkind-kind2\n----------\nNAME   READY\npod-a  1/1\n" +
+				"\nkind-kind4\n----------\nforbidden",
+		},
+		"both": {
+			args: []string{"--group-identical", "--hide-empty", "--", "get", "pods"},
+			want: "\nkind-kind, kind-kind2 (2)\n-------------------------\nNAME   READY\npod-a  1/1\n" +
+				"\nkind-kind4 (1)\n--------------\nforbidden",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			list := mocks.NewMockCmd(ctrl)
+			list.EXPECT().Output().Return([]byte("kind-kind\nkind-kind1\nkind-kind2\nkind-kind3\nkind-kind4\n"), nil)
+			outputs := map[string]string{
+				"kind-kind":  "NAME   READY\npod-a  1/1\n",
+				"kind-kind1": "",
+				"kind-kind2": "NAME   READY\npod-a  1/1\n",
+				"kind-kind3": "",
+			}
+
+			mc := New("")
+			mc.getListContextsCmd = func() Cmd {
+				return list
+			}
+			mc.getKubectlCmd = func(ctx context.Context, args []string, c string, namespace string) Cmd {
+				m := mocks.NewMockCmd(ctrl)
+				if o, ok := outputs[c]; ok {
+					m.EXPECT().Output().Return([]byte(o), nil)
+				} else {
+					m.EXPECT().Output().Return(nil, fmt.Errorf("forbidden"))
+				}
+				return m
+			}
+			b := bytes.NewBuffer([]byte(``))
+			mc.Cmd.SetOut(b)
+			// the order of the contexts must not depend on the durations of previous tests
+			mc.Cmd.SetArgs(append([]string{"-p", "1", "--durations-file", ""}, test.args...))
+			assert.NoError(t, mc.Cmd.Execute())
+			assert.Equal(t, test.want, b.String())
+		})
+	}
+
+	mc := New("")
+	mc.Cmd.SetOut(ioutil.Discard)
+	mc.Cmd.SetErr(ioutil.Discard)
+	mc.Cmd.SetArgs([]string{"--group-identical", "-o", "csv", "--", "get", "pods"})
+	assert.Equal(t, errGroupIdenticalUnsupported, mc.Cmd.Execute())
+}
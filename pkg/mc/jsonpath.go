@@ -0,0 +1,119 @@
+package mc
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// jsonPath evaluates a simple jsonpath expression against a decoded json object. Supported are field access via
+// `.field` or `['field']`, array indexes via `[0]` and wildcards via `[*]`, like in `{.spec.containers[*].image}`.
+// The enclosing braces and a leading `$` are optional
+func jsonPath(obj interface{}, path string) ([]interface{}, error) {
+	p := strings.TrimSpace(path)
+	p = strings.TrimPrefix(strings.TrimSuffix(p, "}"), "{")
+	p = strings.TrimPrefix(p, "$")
+
+	values := []interface{}{obj}
+	for len(p) > 0 {
+		var next []interface{}
+		switch {
+		case strings.HasPrefix(p, "['"):
+			end := strings.Index(p, "']")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated field in jsonpath %q", path)
+			}
+			next = fields(values, p[2:end])
+			p = p[end+2:]
+		case strings.HasPrefix(p, "["):
+			end := strings.Index(p, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated index in jsonpath %q", path)
+			}
+			var err error
+			if next, err = indexes(values, p[1:end]); err != nil {
+				return nil, fmt.Errorf("invalid index in jsonpath %q: %v", path, err)
+			}
+			p = p[end+1:]
+		case strings.HasPrefix(p, "."):
+			p = p[1:]
+			end := strings.IndexAny(p, ".[")
+			if end < 0 {
+				end = len(p)
+			}
+			if end == 0 {
+				continue
+			}
+			next = fields(values, p[:end])
+			p = p[end:]
+		default:
+			return nil, fmt.Errorf("invalid jsonpath %q", path)
+		}
+		values = next
+	}
+	return values, nil
+}
+
+// fields returns the given field of all objects in values. Values that aren't objects or don't have the field are
+// skipped
+func fields(values []interface{}, field string) (next []interface{}) {
+	for _, v := range values {
+		if o, ok := v.(map[string]interface{}); ok {
+			if f, ok := o[field]; ok {
+				next = append(next, f)
+			}
+		}
+	}
+	return
+}
+
+// indexes returns the element at index i or all elements for `*` of all arrays in values
+func indexes(values []interface{}, i string) (next []interface{}, err error) {
+	n := 0
+	if i != "*" {
+		if n, err = strconv.Atoi(i); err != nil {
+			return nil, err
+		}
+	}
+	for _, v := range values {
+		a, ok := v.([]interface{})
+		if !ok {
+			continue
+		}
+		if i == "*" {
+			next = append(next, a...)
+			continue
+		}
+		idx := n
+		if idx < 0 {
+			idx += len(a)
+		}
+		if idx >= 0 && idx < len(a) {
+			next = append(next, a[idx])
+		}
+	}
+	return
+}
+
+// jsonPathString evaluates a jsonpath expression and joins all results into a single comma-separated string.
+// Strings are returned as is, all other values as json
+func jsonPathString(obj interface{}, path string) (string, error) {
+	values, err := jsonPath(obj, path)
+	if err != nil {
+		return "", err
+	}
+	s := make([]string, 0, len(values))
+	for _, v := range values {
+		if str, ok := v.(string); ok {
+			s = append(s, str)
+			continue
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		s = append(s, string(b))
+	}
+	return strings.Join(s, ","), nil
+}
@@ -0,0 +1,79 @@
+package mc
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONPathString(t *testing.T) {
+	var obj interface{}
+	assert.NoError(t, json.Unmarshal([]byte(`{
+  "metadata": {"name": "coredns", "labels": {"app.kubernetes.io/name": "dns"}},
+  "spec": {"replicas": 2, "containers": [{"image": "coredns:1.7"}, {"image": "sidecar:1.0"}]}
+}`), &obj))
+
+	tests := map[string]struct {
+		path    string
+		want    string
+		wantErr bool
+	}{
+		"field": {
+			path: ".metadata.name",
+			want: "coredns",
+		},
+		"braces": {
+			path: "{.metadata.name}",
+			want: "coredns",
+		},
+		"quoted field": {
+			path: ".metadata.labels['app.kubernetes.io/name']",
+			want: "dns",
+		},
+		"number": {
+			path: "$.spec.replicas",
+			want: "2",
+		},
+		"index": {
+			path: ".spec.containers[1].image",
+			want: "sidecar:1.0",
+		},
+		"negative index": {
+			path: ".spec.containers[-1].image",
+			want: "sidecar:1.0",
+		},
+		"wildcard": {
+			path: ".spec.containers[*].image",
+			want: "coredns:1.7,sidecar:1.0",
+		},
+		"object": {
+			path: ".metadata.labels",
+			want: `{"app.kubernetes.io/name":"dns"}`,
+		},
+		"missing": {
+			path: ".status.phase",
+			want: "",
+		},
+		"invalid index": {
+			path:    ".spec.containers[a]",
+			wantErr: true,
+		},
+		"invalid": {
+			path:    "metadata",
+			wantErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := jsonPathString(obj, test.path)
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package mc
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+// junitTestSuites is the root element of a JUnit XML report
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+// junitTestSuite represents a single mc invocation
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+// junitTestCase represents the execution against a single context and namespace
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+// junitFailure carries the stderr of a failed kubectl execution
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Output  string `xml:",chardata"`
+}
+
+// writeJUnit writes a JUnit XML report of all results to path
+func writeJUnit(path string, args []string, results []Result, start time.Time, duration time.Duration) error {
+	sortResults(results)
+
+	suite := junitTestSuite{
+		Name:      "kubectl " + strings.Join(args, " "),
+		Tests:     len(results),
+		Time:      junitTime(duration),
+		Timestamp: start.UTC().Format(time.RFC3339),
+	}
+	for _, r := range results {
+		tc := junitTestCase{Name: r.key(), ClassName: "mc", Time: junitTime(r.Duration)}
+		if r.Err != nil {
+			suite.Failures++
+			tc.Failure = &junitFailure{Message: firstLine(r.Err.Error()), Output: r.Err.Error()}
+		}
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+
+	b, err := xml.MarshalIndent(junitTestSuites{Suites: []junitTestSuite{suite}}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append([]byte(xml.Header), append(b, '\n')...), 0644)
+}
+
+// junitTime formats a duration as seconds, which is what JUnit reports expect
+func junitTime(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
@@ -0,0 +1,33 @@
+package mc
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteJUnit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "junit.xml")
+	results := []Result{
+		{Context: "kind-kind1", Err: fmt.Errorf("Unable to connect to the server\ndial tcp: i/o timeout"), Duration: 30 * time.Second},
+		{Context: kubeContext, Namespace: namespace, Stdout: kubectlReturn, Duration: 1500 * time.Millisecond},
+	}
+
+	assert.NoError(t, writeJUnit(path, []string{"get", "pods"}, results, time.Date(2021, 3, 21, 3, 59, 54, 0, time.UTC), 31*time.Second))
+	got, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="kubectl get pods" tests="2" failures="1" time="31.000" timestamp="2021-03-21T03:59:54Z">
+    <testcase name="kind-kind: default" classname="mc" time="1.500"></testcase>
+    <testcase name="kind-kind1" classname="mc" time="30.000">
+      <failure message="Unable to connect to the server">Unable to connect to the server&#xA;dial tcp: i/o timeout</failure>
+    </testcase>
+  </testsuite>
+</testsuites>
+`, string(got))
+}
@@ -0,0 +1,64 @@
+package mc
+
+import (
+	"encoding/json"
+)
+
+var configViewArgs = []string{"config", "view", "-o", "json"}
+
+// kubeconfig holds the parts of the merged kubeconfig that mc needs, as returned by `kubectl config view -o json`
+type kubeconfig struct {
+	Contexts []struct {
+		Name    string `json:"name"`
+		Context struct {
+			Cluster   string `json:"cluster"`
+			User      string `json:"user"`
+			Namespace string `json:"namespace"`
+		} `json:"context"`
+	} `json:"contexts"`
+	Clusters []struct {
+		Name    string `json:"name"`
+		Cluster struct {
+			Server string `json:"server"`
+		} `json:"cluster"`
+	} `json:"clusters"`
+}
+
+// kubeContextInfo describes a single context of the kubeconfig
+type kubeContextInfo struct {
+	Cluster   string
+	User      string
+	Namespace string
+	Server    string
+}
+
+// loadKubeconfig runs the given config view command and parses its output
+func loadKubeconfig(cmd Cmd) (*kubeconfig, error) {
+	stdout, err := kubectl(cmd)
+	if err != nil {
+		return nil, err
+	}
+	k := &kubeconfig{}
+	if err := json.Unmarshal(stdout, k); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// context returns the cluster, user, namespace and server of the context with the given name.
+// Missing fields are empty
+func (k *kubeconfig) context(name string) (info kubeContextInfo) {
+	for _, c := range k.Contexts {
+		if c.Name == name {
+			info.Cluster, info.User, info.Namespace = c.Context.Cluster, c.Context.User, c.Context.Namespace
+			break
+		}
+	}
+	for _, c := range k.Clusters {
+		if c.Name == info.Cluster {
+			info.Server = c.Cluster.Server
+			break
+		}
+	}
+	return
+}
@@ -1,15 +1,14 @@
 package mc
 
 import (
-	"bufio"
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
+	"os"
 	"os/exec"
-	"regexp"
+	"path/filepath"
 	"strings"
-	"sync"
+	"time"
 
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
@@ -21,13 +20,20 @@ const (
 	YAML = "yaml"
 	// JSON represents the string for json
 	JSON = "json"
+
+	// emptyNamespace is used in file names for executions without a namespace.
+	// It can't collide with a real namespace as those have to be valid DNS labels
+	emptyNamespace = "_"
 )
 
 var (
-	logger  *zap.Logger
+	logger  = zap.NewNop()
 	outputs = map[string]bool{
-		YAML: true,
-		JSON: true,
+		YAML:       true,
+		JSON:       true,
+		CSV:        true,
+		TSV:        true,
+		Prometheus: true,
 	}
 
 	errUnknownOutput      = fmt.Errorf("this output format is unknown. Choose one of %s", outputsString())
@@ -42,12 +48,65 @@ type MC struct {
 	Namespaces string
 	ListOnly   bool
 	MaxProc    int
+	MaxProcCtx int
 	Debug      bool
 	Output     string
+	Record     string
+	Replay     string
+	OutputDir  string
+	OutputTmpl string
+	Columns    string
+	JUnit      string
+	Template   bool
+	PrintArgs  bool
+	Plan       bool
+	Preview    bool
+	PlanFormat string
+	ConfigPath string
+	AuditLog   string
+	Pick       bool
+	Last       bool
+	Watch      time.Duration
+	Color      string
+	GroupIdent bool
+	HideEmpty  bool
+	ErrSummary bool
+
+	PrewarmAuth       bool
+	IsolateKubeconfig bool
+	DurationsFile     string
+	PerNamespace      bool
+	DaemonSocket      string
+	NoDaemon          bool
+	CacheTTL          time.Duration
+	CacheDir          string
+	NoCache           bool
+	MetricsFile       string
+	Metrics           []string
+	TraceFile         string
+	TraceEndpoint     string
+
+	config     *config
+	kubeconfig *kubeconfig
+	// color is true if the output is colored
+	color bool
+	// kubeconfigs are the paths of the isolated kubeconfigs by context
+	kubeconfigs map[string]string
+	// argv are the args of the current invocation, as written to the audit log
+	argv []string
+	// tracer collects the spans of the current invocation if tracing is enabled
+	tracer *tracer
 
 	// to allow dependency injection
-	getListContextsCmd func() Cmd
-	getKubectlCmd      func(args []string, context string, namespace string) Cmd
+	getListContextsCmd  func() Cmd
+	getConfigViewCmd    func() Cmd
+	getKubectlCmd       func(ctx context.Context, args []string, kubeContext string, namespace string) Cmd
+	getCompletionCmd    func(args []string) Cmd
+	getRawKubeconfigCmd func() Cmd
+	makeRaw             func() (func(), error)
+	exit                func(code int)
+	// directKubectlCmd is getKubectlCmd without the delegation to the daemon
+	directKubectlCmd func(ctx context.Context, args []string, kubeContext string, namespace string) Cmd
 }
 
 // Cmd is an interface for exec.Cmd to allow for dependency injection
@@ -63,11 +122,22 @@ func New(version string) *MC {
 
 	// to allow dependency injection
 	mc.getListContextsCmd = func() Cmd {
-		return exec.Command("kubectl", []string{"config", "get-contexts", "-o", "name"}...)
+		return exec.Command("kubectl", listContextsArgs...)
+	}
+	mc.getConfigViewCmd = func() Cmd {
+		return exec.Command("kubectl", configViewArgs...)
+	}
+	mc.getKubectlCmd = func(ctx context.Context, args []string, kubeContext string, namespace string) Cmd {
+		return exec.CommandContext(ctx, "kubectl", getLocalArgs(args, kubeContext, namespace)...)
+	}
+	mc.getCompletionCmd = func(args []string) Cmd {
+		return exec.Command("kubectl", args...)
 	}
-	mc.getKubectlCmd = func(args []string, context string, namespace string) Cmd {
-		return exec.Command("kubectl", getLocalArgs(args, context, namespace)...)
+	mc.getRawKubeconfigCmd = func() Cmd {
+		return exec.Command("kubectl", rawKubeconfigArgs...)
 	}
+	mc.makeRaw = makeRaw
+	mc.exit = os.Exit
 
 	cmd := &cobra.Command{
 		Use:   "mc [flags] -- [kubectl command]",
@@ -93,15 +163,146 @@ mc -r gke -x 'dev' -p 5 -d -- get pods -n gatekeeper-system -l app.kubernetes.io
 mc -r prod -n ns-1,ns-2,ns-3 -p 10 -- get deployments
 
 # print the context and the pod names in kube-system using jq
-mc -r kind -o json -- get pods -n kube-system | jq 'keys[] as $k | "\($k) \(.[$k] | .items[].metadata.name)"'`,
+mc -r kind -o json -- get pods -n kube-system | jq 'keys[] as $k | "\($k) \(.[$k] | .items[].metadata.name)"'
+
+# save a snapshot of all nodes of the prod clusters and query it later without calling any cluster
+mc -r prod --record ./snapshot -o json -- get nodes
+mc --replay ./snapshot -o yaml -- get nodes
+
+# write all resources of every staging cluster into one yaml file per context and namespace
+mc -r staging -n default,kube-system -o yaml --output-dir ./staging -- get all
+
+# export the nodes of all prod clusters with their kubelet version as csv
+mc -r prod -o csv --columns NAME:.metadata.name,VERSION:.status.nodeInfo.kubeletVersion -- get nodes > nodes.csv
+
+# smoke test all staging clusters in CI and report the result of every cluster as JUnit test case
+mc -r staging --junit report.xml -- get --raw /readyz
+
+# roll out an image from the registry of the region of every cluster, with the region defined in the mc config file
+mc -r prod --template --print-args -- set image deploy/app app=registry.{{.Region}}.example.com/app:1.2
+mc -r prod --template -- set image deploy/app app=registry.{{.Region}}.example.com/app:1.2
+
+# print which commands would delete the debug pods of all dev clusters, or save them as shell script
+mc -r dev -n default,debug --plan -- delete pod debug
+mc -r dev -n default,debug --plan --plan-format shell -- delete pod debug > delete-debug.sh
+
+# preview the changes of an apply to all prod clusters via kubectl diff and confirm before applying
+mc -r prod --preview -- apply -f manifests/
+
+# pick the contexts to get the pods from interactively, then get the deployments of the same contexts
+mc --pick -- get pods
+mc --last -- get deployments
+
+# watch the pods of all kind clusters every 5 seconds, or stream the changes as JSONL events
+mc -r kind --watch 5s -- get pods
+mc -r kind --watch 5s -o json -- get pods | jq -c '{context, pods: [.output.items[].metadata.name]}'
+
+# get the pods of all clusters with colored output, also when piping into less
+mc --color always -- get pods | less -R
+
+# get the version of the ingress controller in all clusters, printing every distinct version only once
+mc --group-identical -- get deploy ingress-nginx -n ingress -o jsonpath='{.spec.template.spec.containers[0].image}'
+
+# only show the clusters that have failed pods
+mc --hide-empty -- get pods -A --field-selector status.phase=Failed
+
+# get the pods of all clusters and summarize the failed ones by the cause of their error
+mc --summarize-errors -- get pods
+
+# get the pods of all EKS clusters with 10 parallel processes, refreshing every token only once and isolating the kubeconfig of every process
+mc -r eks -p 10 --prewarm-auth --isolate-kubeconfig -- get pods
+
+# get the pods of four namespaces in all prod clusters, with at most 2 parallel kubectl per cluster
+mc -r prod -n team-a,team-b,team-c,team-d -p 10 --per-context-processes 2 -- get pods
+
+# get the deployments of three namespaces per namespace, if the user isn't allowed to list deployments cluster-wide
+mc -n team-a,team-b,team-c -o yaml --per-namespace -- get deploy
+
+# start a daemon holding authenticated proxies of all contexts, which all following invocations are delegated to
+mc daemon --allow-local-proxies --warm &
+mc -- get pods
+
+# serve an HTTP API for read-only fleet queries on localhost:8080
+MC_SERVE_TOKEN=secret mc serve
+
+# get the nodes of all clusters, served from the cache if the last run was less than 5 minutes ago
+mc --cache-ttl 5m -- get nodes
+
+# write the success, duration, number of deployments and ready replicas of every cluster for the node exporter
+mc -o prometheus --metric ready_replicas:.items[*].status.readyReplicas -- get deploy > /var/lib/node_exporter/mc.prom
+
+# send a trace with a span for every cluster to a local OpenTelemetry collector, to find out why a run is slow
+mc --trace-endpoint http://localhost:4318 -- get pods
+
+# get the nodes of all clusters of the group eu, defined as named regex in the mc config file
+mc -r eu -- get nodes
+
+# load shell completion for contexts, groups and kubectl args into the current bash session
+source <(kubectl-mc completion bash)`,
 		SilenceUsage: true,
 		Version:      version,
+		Args:         cobra.ArbitraryArgs,
+
+		ValidArgsFunction: mc.completeKubectlArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			logger, _ = zap.NewProduction()
 			if mc.Debug {
 				logger, _ = zap.NewDevelopment()
 			}
 			defer logger.Sync()
+			if mc.Record != "" && mc.Replay != "" {
+				return errRecordAndReplay
+			}
+			if mc.Replay != "" {
+				mc.useReplay()
+			}
+			var err error
+			if mc.color, err = mc.useColor(); err != nil {
+				return err
+			}
+			mc.directKubectlCmd = mc.getKubectlCmd
+			if mc.Replay == "" && !mc.NoDaemon && daemonRunning(mc.DaemonSocket) {
+				logger.Debug("delegating to daemon", zap.String("socket", mc.DaemonSocket))
+				kubectlCmd := mc.getKubectlCmd
+				mc.getKubectlCmd = func(ctx context.Context, args []string, kubeContext string, namespace string) Cmd {
+					if !delegatable(args) {
+						return kubectlCmd(ctx, args, kubeContext, namespace)
+					}
+					return &daemonCmd{
+						socket:  mc.DaemonSocket,
+						request: newDaemonRequest(args, kubeContext, namespace),
+						direct:  kubectlCmd(ctx, args, kubeContext, namespace),
+					}
+				}
+			}
+			if mc.Pick && mc.Last {
+				return errPickAndLast
+			}
+			if mc.Watch > 0 && (isTabular(mc.Output) || mc.Output == Prometheus || mc.OutputDir != "" || mc.JUnit != "" || mc.MetricsFile != "" || mc.TraceFile != "" || mc.TraceEndpoint != "") {
+				return errWatchUnsupported
+			}
+			if mc.GroupIdent && (isTabular(mc.Output) || mc.Output == Prometheus || mc.OutputDir != "" || mc.Watch > 0) {
+				return errGroupIdenticalUnsupported
+			}
+			if mc.PlanFormat != planFormatText && mc.PlanFormat != planFormatShell {
+				return errUnknownPlanFormat
+			}
+			if _, err := parseColumns(mc.Columns); err != nil {
+				return err
+			}
+			if mc.config, err = loadConfig(mc.ConfigPath); err != nil {
+				return err
+			}
+			mc.Regex, mc.NegRegex = mc.config.expandGroup(mc.Regex), mc.config.expandGroup(mc.NegRegex)
+			if mc.Template {
+				if mc.kubeconfig, err = loadKubeconfig(mc.configViewCmd()); err != nil {
+					return err
+				}
+			}
+			mc.argv = argv(cmd.Flags(), args)
+			if _, err := parseMetrics(mc.Metrics); err != nil {
+				return err
+			}
 			if mc.Output != "" {
 				if _, ok := outputs[mc.Output]; !ok {
 					return errUnknownOutput
@@ -110,25 +311,83 @@ mc -r kind -o json -- get pods -n kube-system | jq 'keys[] as $k | "\($k) \(.[$k
 				for _, arg := range args {
 					raw = raw || strings.HasPrefix(arg, "--raw")
 				}
-				if !raw {
+				// tabular output without custom columns is parsed from the kubectl table output
+				if !raw && (!isTabular(mc.Output) || mc.Columns != "") {
 					args = append(args, "-o", "json")
 				}
 			}
+			if len(mc.Metrics) > 0 && len(args) > 0 && !jsonOutput(args) {
+				return errMetricsNeedJSON
+			}
 			if len(args) == 0 && !mc.ListOnly {
 				cmd.Usage()
 				return nil
 			}
-			return mc.run(args)
+			if mc.TraceFile == "" && mc.TraceEndpoint == "" {
+				return mc.run(cmd.Context(), args)
+			}
+			mc.tracer = newTracer(args)
+			err = mc.run(cmd.Context(), args)
+			mc.tracer.finish(err)
+			logger.Debug("exporting trace")
+			if terr := mc.exportTrace(); terr != nil && err == nil {
+				return fmt.Errorf("couldn't export trace: %v", terr)
+			}
+			return err
 		},
 	}
 
-	cmd.Flags().StringVarP(&mc.Regex, "regex", "r", mc.Regex, "a regex to filter the list of context names in kubeconfig. If not given all contexts are used")
-	cmd.Flags().StringVarP(&mc.NegRegex, "negative-regex", "x", mc.NegRegex, "a regex to exclude matches from the result set. Evaluated succeeding to the including regex filter")
+	cmd.Flags().StringVarP(&mc.Regex, "regex", "r", mc.Regex, "a regex to filter the list of context names in kubeconfig, or the name of a group in the config file. If not given all contexts are used")
+	cmd.Flags().StringVarP(&mc.NegRegex, "negative-regex", "x", mc.NegRegex, "a regex to exclude matches from the result set, or the name of a group in the config file. Evaluated succeeding to the including regex filter")
 	cmd.Flags().StringVarP(&mc.Namespaces, "namespaces", "n", mc.Namespaces, "comma-separated list of namespaces. Overrides namespace(s) specified in kubectl command. The default is the current namespace of the context")
 	cmd.Flags().BoolVarP(&mc.ListOnly, "list-only", "l", mc.ListOnly, "just list the contexts matching the regex. Good for testing your regex")
 	cmd.Flags().IntVarP(&mc.MaxProc, "max-processes", "p", 5, "max amount of parallel kubectl to be executed. Can be used to limit cpu activity")
+	cmd.Flags().IntVar(&mc.MaxProcCtx, "per-context-processes", mc.MaxProcCtx, "max amount of parallel kubectl against a single context, to not overload one API server when running against many namespaces. 0 means only --max-processes applies")
 	cmd.Flags().BoolVarP(&mc.Debug, "debug", "d", mc.Debug, "enable debug output")
 	cmd.Flags().StringVarP(&mc.Output, "output", "o", mc.Output, fmt.Sprintf("specify the output format. Useful for parsing with another tool like jq or yq. One of %s", outputsString()))
+	cmd.Flags().StringVar(&mc.JUnit, "junit", mc.JUnit, "write a JUnit XML report to this file, with every context and namespace as a test case that fails if kubectl failed")
+	cmd.Flags().BoolVar(&mc.Template, "template", mc.Template, "render the kubectl args as go templates for every context. Available are {{.Context}}, {{.Namespace}}, {{.Cluster}}, {{.User}}, {{.Server}} and the vars of the context in the config file")
+	cmd.Flags().BoolVar(&mc.PrintArgs, "print-args", mc.PrintArgs, "print the kubectl command for every context and namespace instead of executing it. Good for testing your templates")
+	cmd.Flags().BoolVar(&mc.Plan, "plan", mc.Plan, "print which kubectl commands would be executed against which context and namespace, and how many in parallel, without executing anything")
+	cmd.Flags().StringVar(&mc.PlanFormat, "plan-format", planFormatText, fmt.Sprintf("format of --plan. One of %s|%s. The shell format is a script that runs all kubectl commands one after another", planFormatText, planFormatShell))
+	cmd.Flags().BoolVar(&mc.Preview, "preview", mc.Preview, "run the command with --dry-run=server (or kubectl diff for apply) against every context first, print a summary of the changes per context and ask for confirmation before running the real command")
+	cmd.Flags().BoolVar(&mc.Pick, "pick", mc.Pick, "interactively pick the contexts from the ones matching the regex, with filter-as-you-type. The picked contexts are remembered as last selection")
+	cmd.Flags().BoolVar(&mc.Last, "last", mc.Last, "use the contexts of the last selection picked with --pick")
+	cmd.Flags().DurationVar(&mc.Watch, "watch", mc.Watch, "execute the command repeatedly with this interval, like 2s. The output is redrawn in place with changed lines highlighted. With -o json or yaml a JSONL event is written for every context and namespace whose output changed")
+	cmd.Flags().StringVar(&mc.Color, "color", colorAuto, fmt.Sprintf("colorize the output. One of %s|%s|%s. Auto colorizes if stdout is a terminal and NO_COLOR isn't set", colorAuto, colorAlways, colorNever))
+	cmd.Flags().BoolVar(&mc.GroupIdent, "group-identical", mc.GroupIdent, "print identical outputs only once, under a header listing all contexts that produced them and their count")
+	cmd.Flags().BoolVar(&mc.HideEmpty, "hide-empty", mc.HideEmpty, "drop the results of contexts with an empty output or no resources found")
+	cmd.Flags().BoolVar(&mc.ErrSummary, "summarize-errors", mc.ErrSummary, "instead of printing the error of every failed context, print a summary to stderr at the end that groups the contexts by the cause of the error, with a hint how to fix it")
+	cmd.Flags().BoolVar(&mc.PrewarmAuth, "prewarm-auth", mc.PrewarmAuth, "resolve the credentials of every unique user one after another before executing kubectl in parallel, so credential plugins don't race on refreshing the same token")
+	cmd.Flags().BoolVar(&mc.IsolateKubeconfig, "isolate-kubeconfig", mc.IsolateKubeconfig, "run every kubectl process with a minimized copy of the kubeconfig that only contains its context, so concurrent writes of credential plugins can't corrupt the kubeconfig")
+	cmd.Flags().StringVar(&mc.DurationsFile, "durations-file", filepath.Join(stateDir(), durationsFile), "remember the average duration of every context in this file, to start the historically slowest contexts first. Set to an empty string to keep the order of the kubeconfig")
+	cmd.Flags().BoolVar(&mc.PerNamespace, "per-namespace", mc.PerNamespace, "execute a get against every namespace separately. By default a get by type against multiple namespaces with json or yaml output lists all namespaces once per context and filters the items locally, which requires the permission to list cluster-wide")
+	cmd.Flags().BoolVar(&mc.NoDaemon, "no-daemon", mc.NoDaemon, "execute kubectl directly, even if a daemon started with `mc daemon` is running")
+	cmd.Flags().DurationVar(&mc.CacheTTL, "cache-ttl", mc.CacheTTL, "serve the results of read-only commands like get or describe from a cache in --cache-dir if they are younger than this, like 5m. Cached results are marked with their age. 0 disables the cache")
+	cmd.Flags().BoolVar(&mc.NoCache, "no-cache", mc.NoCache, "execute every command even if --cache-ttl is set, and refresh the cache with the results")
+	cmd.Flags().StringVar(&mc.CacheDir, "cache-dir", filepath.Join(stateDir(), cacheDir), "the directory of the --cache-ttl cache")
+	cmd.Flags().StringVar(&mc.MetricsFile, "metrics-file", mc.MetricsFile, "write the success and duration of every execution, the number of items of every list and the --metric gauges per context and namespace to this file in the prometheus text format, for the textfile collector of the node exporter")
+	cmd.Flags().StringArrayVar(&mc.Metrics, "metric", mc.Metrics, "a custom gauge for -o prometheus and --metrics-file in the format NAME:JSONPATH, whose value is the sum of all values the jsonpath matches in the output, like ready_replicas:.items[*].status.readyReplicas. Can be given multiple times")
+	cmd.Flags().StringVar(&mc.TraceFile, "trace-file", mc.TraceFile, "append an OTLP/JSON trace of the invocation to this file, with a span for every kubectl execution including its queue wait, run time and exit code")
+	cmd.Flags().StringVar(&mc.TraceEndpoint, "trace-endpoint", mc.TraceEndpoint, "send an OTLP/JSON trace of the invocation to the OTLP/HTTP endpoint of a collector, like http://localhost:4318")
+	cmd.Flags().StringVar(&mc.ConfigPath, "config", filepath.Join(stateDir(), configFile), "path to the mc config file")
+	cmd.Flags().StringVar(&mc.Record, "record", mc.Record, "save the argv, stdout, stderr and exit code of every kubectl execution into this directory")
+	cmd.Flags().StringVar(&mc.Columns, "columns", mc.Columns, "custom columns for csv and tsv output in the format NAME:JSONPATH,... like NAME:.metadata.name,NODE:.spec.nodeName. Every item of a list becomes a row. The default are the columns of the kubectl table output")
+	cmd.Flags().StringVar(&mc.OutputDir, "output-dir", mc.OutputDir, "write the result of every context and namespace into its own file in this directory instead of stdout, next to an index file summarizing the status of every execution")
+	cmd.Flags().StringVar(&mc.OutputTmpl, "output-template", defaultOutputTemplate, fmt.Sprintf("go template for the file names within --output-dir. Available fields are .Context and .Namespace, which is %q if no namespace was given", emptyNamespace))
+	cmd.Flags().StringVar(&mc.Replay, "replay", mc.Replay, "replay a run previously saved with --record from this directory instead of calling kubectl")
+
+	cmd.PersistentFlags().StringVar(&mc.DaemonSocket, "daemon-socket", filepath.Join(stateDir(), daemonSocketFile), "the unix socket of the daemon")
+	cmd.PersistentFlags().StringVar(&mc.AuditLog, "audit-log", filepath.Join(stateDir(), auditLogFile), "append an entry for every invocation to this audit log. Set to an empty string to disable the audit log")
+
+	cmd.RegisterFlagCompletionFunc("regex", mc.completeRegex)
+	cmd.RegisterFlagCompletionFunc("negative-regex", mc.completeRegex)
+
+	cmd.AddCommand(mc.newHistoryCmd())
+	cmd.AddCommand(mc.newCompletionCmd())
+	cmd.AddCommand(mc.newWaitCmd())
+	cmd.AddCommand(mc.newDaemonCmd())
+	cmd.AddCommand(mc.newServeCmd())
 
 	mc.Cmd = cmd
 
@@ -137,13 +396,28 @@ mc -r kind -o json -- get pods -n kube-system | jq 'keys[] as $k | "\($k) \(.[$k
 
 // run executed the main command by listing matched kubernetes contexts and executing the
 // given kubectl args against every context in parallel
-// The `do` parameter allows for dependency injection
-func (mc *MC) run(args []string) error {
-	contexts, err := mc.listContexts(mc.getListContextsCmd())
+func (mc *MC) run(ctx context.Context, args []string) error {
+	var onResult func(Result)
+	if mc.Output == "" && mc.OutputDir == "" && !mc.GroupIdent {
+		onResult = mc.printResult
+	}
+	r := mc.runner(onResult)
+	contexts, err := r.ListContexts(ctx)
 	if err != nil {
 		return err
 	}
 
+	if mc.Pick {
+		if contexts, err = mc.pick(contexts); err != nil {
+			return err
+		}
+	}
+	if mc.Last {
+		if contexts, err = lastSelection(contexts); err != nil {
+			return err
+		}
+	}
+
 	if mc.ListOnly {
 		for _, c := range contexts {
 			fmt.Fprintln(mc.Cmd.OutOrStdout(), c)
@@ -151,40 +425,105 @@ func (mc *MC) run(args []string) error {
 		return nil
 	}
 
-	logger.Debug("preparing wait group", zap.Int("max-processes", mc.MaxProc))
-	parallelProc := make(chan bool, mc.MaxProc)
-	for i := 0; i < mc.MaxProc; i++ {
-		parallelProc <- true
+	if mc.Plan {
+		return mc.printPlan(contexts, args)
+	}
+	if mc.PrintArgs {
+		return mc.printArgs(contexts, args)
 	}
+	cleanup, err := mc.prepareAuth(contexts)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
 
-	done := make(chan bool)
-	wait := make(chan bool)
-	var mutex = &sync.Mutex{}
+	if mc.Preview {
+		proceed, err := mc.preview(ctx, contexts, args)
+		if err != nil || !proceed {
+			return err
+		}
+	}
 
-	namespaces := strings.Split(mc.Namespaces, ",")
+	if mc.Watch > 0 {
+		return mc.watch(ctx, contexts, args)
+	}
 
-	logger.Debug("start wait group")
-	go func() {
-		for i := 0; i < len(contexts)*len(namespaces); i++ {
-			<-done
-			parallelProc <- true
+	start := time.Now()
+	if mc.tracer != nil {
+		mc.tracer.queue()
+	}
+	var results []Result
+	if mc.listAllNamespaces(args) {
+		logger.Debug("listing all namespaces once per context")
+		results, err = mc.runAllNamespaces(ctx, mc.prioritize(contexts), args)
+	} else {
+		results, err = r.RunContexts(ctx, mc.prioritize(contexts), args)
+	}
+	if mc.AuditLog != "" {
+		logger.Debug("writing audit log", zap.String("file", mc.AuditLog))
+		if err := mc.audit(mc.argv, contexts, results); err != nil {
+			return fmt.Errorf("couldn't write audit log: %v", err)
 		}
-		logger.Debug("wait group finished")
-		wait <- true
-	}()
-
-	output := map[string]json.RawMessage{}
-	for _, c := range contexts {
-		for _, ns := range namespaces {
-			logger.Debug("waiting for next free spot", zap.String("context", c), zap.String("namespace", ns))
-			<-parallelProc
-			logger.Debug("executing", zap.String("context", c), zap.String("namespace", ns))
-			go do(done, c, ns, output, mc.Output == "", mc.Cmd.OutOrStdout(), mc.getKubectlCmd(args, c, ns), mutex)
+	}
+	if err != nil {
+		return err
+	}
+	// replayed results are read from files and didn't take as long as the context would have
+	if mc.DurationsFile != "" && mc.Replay == "" {
+		logger.Debug("saving durations", zap.String("file", mc.DurationsFile))
+		if err := mc.saveDurations(results); err != nil {
+			return fmt.Errorf("couldn't save durations: %v", err)
+		}
+	}
+	if mc.JUnit != "" {
+		logger.Debug("writing junit report", zap.String("file", mc.JUnit))
+		if err := writeJUnit(mc.JUnit, args, results, start, time.Since(start)); err != nil {
+			return err
 		}
 	}
-	<-wait
+	if mc.MetricsFile != "" {
+		logger.Debug("writing metrics file", zap.String("file", mc.MetricsFile))
+		gauges, _ := parseMetrics(mc.Metrics)
+		if err := writeMetricsFile(mc.MetricsFile, results, gauges); err != nil {
+			return fmt.Errorf("couldn't write metrics file: %v", err)
+		}
+	}
+	if mc.ErrSummary {
+		defer writeErrorSummary(mc.Cmd.ErrOrStderr(), results)
+	}
+	if mc.HideEmpty {
+		results = withoutEmpty(results)
+	}
+	if mc.OutputDir != "" {
+		logger.Debug("writing output directory", zap.String("dir", mc.OutputDir))
+		return mc.writeOutputDir(results)
+	}
+	if isTabular(mc.Output) {
+		return mc.writeTable(mc.Cmd.OutOrStdout(), results)
+	}
+	if mc.Output == Prometheus {
+		gauges, _ := parseMetrics(mc.Metrics)
+		return writeMetrics(mc.Cmd.OutOrStdout(), results, gauges)
+	}
+	if mc.Output == "" && mc.GroupIdent {
+		mc.printGroups(groupIdentical(results))
+	}
 	if mc.Output != "" {
 		logger.Debug("parsing output...")
+		output := map[string]json.RawMessage{}
+		if mc.GroupIdent {
+			for _, g := range groupIdentical(results) {
+				if g.result.Err == nil {
+					output[strings.Join(g.keys, ", ")] = markCached(g.result)
+				}
+			}
+		} else {
+			for _, r := range results {
+				if r.Err == nil {
+					output[r.key()] = markCached(r)
+				}
+			}
+		}
 		o, err := json.MarshalIndent(output, "", "  ")
 		if err != nil {
 			logger.Debug("failed to parse output", zap.String("retrieved", fmt.Sprintf("%s", output)))
@@ -192,13 +531,13 @@ func (mc *MC) run(args []string) error {
 		}
 		switch mc.Output {
 		case JSON:
-			fmt.Fprintf(mc.Cmd.OutOrStdout(), "%s", o)
+			mc.writeStructured(mc.Cmd.OutOrStdout(), o)
 		case YAML:
 			o, err := yaml.JSONToYAML(o)
 			if err != nil {
 				return err
 			}
-			fmt.Fprintf(mc.Cmd.OutOrStdout(), "%s", o)
+			mc.writeStructured(mc.Cmd.OutOrStdout(), o)
 		}
 	}
 	logger.Debug("done")
@@ -206,95 +545,82 @@ func (mc *MC) run(args []string) error {
 	return nil
 }
 
-// list context builds a list of context based on a given regex
-func (mc *MC) listContexts(cmd Cmd) (contexts []string, err error) {
-	r, err := regexp.Compile(mc.Regex)
-	if err != nil {
-		return nil, err
+// runner returns a Runner configured by the flags of the command, calling onResult for every result
+func (mc *MC) runner(onResult func(Result)) *Runner {
+	return NewRunner(Options{
+		Regex:             mc.Regex,
+		NegRegex:          mc.NegRegex,
+		Namespaces:        strings.Split(mc.Namespaces, ","),
+		MaxProc:           mc.MaxProc,
+		MaxProcPerContext: mc.MaxProcCtx,
+		OnResult:          onResult,
+		ListContextsCmd: func(ctx context.Context) Cmd {
+			return mc.listContextsCmd()
+		},
+		KubectlCmd: func(ctx context.Context, args []string, kubeContext string, namespace string) Cmd {
+			return mc.kubectlCmd(ctx, args, kubeContext, namespace)
+		},
+	})
+}
+
+// printResult prints a result in text mode
+func (mc *MC) printResult(r Result) {
+	if (mc.HideEmpty && isEmpty(r)) || (mc.ErrSummary && r.Err != nil) {
+		return
 	}
-	nr, err := regexp.Compile(mc.NegRegex)
-	if err != nil {
-		return nil, err
+	stdout := r.Stdout
+	if r.Err != nil {
+		stdout = []byte(r.Err.Error())
 	}
+	fmt.Fprint(mc.Cmd.OutOrStdout(), mc.formatText(r, string(stdout)))
+}
 
-	stdout, err := kubectl(cmd)
-	if err != nil {
-		return nil, err
+// listContextsCmd returns the command listing all contexts, wrapped to be recorded if requested
+func (mc *MC) listContextsCmd() Cmd {
+	cmd := mc.getListContextsCmd()
+	if mc.Record != "" {
+		argv := append([]string{"kubectl"}, listContextsArgs...)
+		cmd = &recordCmd{cmd: cmd, dir: listContextsRecordingDir(mc.Record), argv: argv}
 	}
+	return cmd
+}
 
-	s := bufio.NewScanner(bytes.NewReader(stdout))
-	for s.Scan() {
-		context := s.Bytes()
-		if r.Match(context) {
-			if mc.NegRegex != "" && nr.Match(context) {
-				continue
-			}
-			contexts = append(contexts, string(context))
-		}
+// configViewCmd returns the command printing the kubeconfig, wrapped to be recorded if requested
+func (mc *MC) configViewCmd() Cmd {
+	cmd := mc.getConfigViewCmd()
+	if mc.Record != "" {
+		argv := append([]string{"kubectl"}, configViewArgs...)
+		cmd = &recordCmd{cmd: cmd, dir: configViewRecordingDir(mc.Record), argv: argv}
 	}
-
-	return
+	return cmd
 }
 
-// do executes a command against kubectl and sends a bool to the done channel when done
-func do(done chan bool, context string, namespace string, output map[string]json.RawMessage, writeToStdout bool, out io.Writer, cmd Cmd, mutex *sync.Mutex) {
-	stdout, err := kubectl(cmd)
+// kubectlCmd returns the command executing args against a context and namespace, wrapped to be cached, recorded or
+// traced if requested
+func (mc *MC) kubectlCmd(ctx context.Context, args []string, kubeContext string, namespace string) Cmd {
+	args, err := mc.renderArgs(args, kubeContext, namespace)
 	if err != nil {
-		stdout = []byte(err.Error())
-		logger.Debug("kubectl error", zap.Error(err))
-	} else {
-		mutex.Lock()
-
-		cns := context
-		if namespace != "" {
-			cns += ": " + namespace
-		}
-		output[cns] = stdout
-		mutex.Unlock()
+		return &errorCmd{err: err}
 	}
-	if writeToStdout {
-		fmt.Fprint(out, formatContext(context, namespace, stdout))
+	// the isolated kubeconfig is a new temp file on every run, so it isn't part of the cache key or the span name
+	cacheArgs := args
+	if path, ok := mc.kubeconfigs[kubeContext]; ok {
+		args = append([]string{"--kubeconfig", path}, args...)
 	}
-	done <- true
-}
-
-// kubectl executes a kubectl command
-func kubectl(cmd Cmd) ([]byte, error) {
-	out, err := cmd.Output()
-	if err != nil {
-		errString := err.Error()
-		if err, ok := err.(*exec.ExitError); ok {
-			errString = string(err.Stderr)
-		}
-		return nil, fmt.Errorf(strings.Replace(strings.Replace(errString, "error: ", "", -1), "Error: ", "", -1))
+	cmd := mc.cached(mc.getKubectlCmd(ctx, args, kubeContext, namespace), cacheArgs, kubeContext, namespace)
+	if mc.Record != "" {
+		argv := append([]string{"kubectl"}, getLocalArgs(args, kubeContext, namespace)...)
+		cmd = &recordCmd{cmd: cmd, dir: recordingDir(mc.Record, kubeContext, namespace), argv: argv}
 	}
-	return out, nil
+	return mc.traced(cmd, cacheArgs, kubeContext, namespace)
 }
 
-// getLocalArgs transforms kubectl args slice by injecting the context flag into the right position.
-// if the kubectl command contained `--` (for instance for a `kubectl exec` command, we inject the context flag before
-// that.
-func getLocalArgs(args []string, context string, namespace string) (localArgs []string) {
-
-	var skipContext bool
-	for _, arg := range args {
-		if arg == "--" {
-			// If this is given, we need to insert the context before this arg
-			localArgs = append(localArgs, "--context", context)
-			if len(namespace) > 0 {
-				localArgs = append(localArgs, "--namespace", namespace)
-			}
-			skipContext = true
-		}
-		localArgs = append(localArgs, arg)
-	}
-	if !skipContext {
-		localArgs = append(localArgs, "--context", context)
-		if len(namespace) > 0 {
-			localArgs = append(localArgs, "--namespace", namespace)
-		}
+// renderArgs renders the args for a context and namespace if templating is enabled
+func (mc *MC) renderArgs(args []string, context string, namespace string) ([]string, error) {
+	if !mc.Template {
+		return args, nil
 	}
-	return
+	return renderArgs(args, templateVars(mc.config, mc.kubeconfig, context, namespace))
 }
 
 // outputStrings is a helper function to transform the output option map keys into a string separated by `|`
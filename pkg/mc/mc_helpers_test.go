@@ -1,8 +1,27 @@
 package mc
 
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// TestMain runs all tests with a temporary home directory, so that no test reads or writes the config and state of
+// the user running the tests
+func TestMain(m *testing.M) {
+	home, err := ioutil.TempDir("", "mc")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("HOME", home)
+	code := m.Run()
+	os.RemoveAll(home)
+	os.Exit(code)
+}
+
 const (
-	context   = "kind-kind"
-	namespace = "default"
+	kubeContext = "kind-kind"
+	namespace   = "default"
 )
 
 var (
@@ -2,10 +2,9 @@ package mc
 
 import (
 	"bytes"
-	"encoding/json"
+	"context"
 	"io/ioutil"
 	"os/exec"
-	"sync"
 	"testing"
 
 	"github.com/golang/mock/gomock"
@@ -93,7 +92,7 @@ coredns-66bff467f8-4lnsg                     1/1     Running   1          22h
 			mc.getListContextsCmd = func() Cmd {
 				return m
 			}
-			mc.getKubectlCmd = func(args []string, context string, namespace string) Cmd {
+			mc.getKubectlCmd = func(ctx context.Context, args []string, c string, namespace string) Cmd {
 				return m
 			}
 			b := bytes.NewBuffer([]byte(``))
@@ -118,7 +117,7 @@ coredns-66bff467f8-4lnsg                     1/1     Running   1          22h
 	}
 }
 
-func TestMC_ListContexts(t *testing.T) {
+func TestRunner_ListContexts(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	m := mocks.NewMockCmd(ctrl)
 
@@ -153,11 +152,11 @@ gke_project-prod_cluster-prod
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
 			m.EXPECT().Output().Return(test.kubectlReturn, nil)
-			mc := MC{
+			r := NewRunner(Options{
 				Regex:    test.regex,
 				NegRegex: test.negRegex,
-			}
-			got, err := mc.listContexts(m)
+			})
+			got, err := r.listContexts(m)
 			assert.NoError(t, err)
 			assert.Equal(t, test.want, got)
 		})
@@ -170,12 +169,9 @@ func TestDo(t *testing.T) {
 
 	m.EXPECT().Output().Return(kubectlReturn, nil)
 
-	done := make(chan bool, 1)
-	var mutex = &sync.Mutex{}
-	output := map[string]json.RawMessage{}
-	do(done, context, namespace, output, false, nil, m, mutex)
-	assert.True(t, <-done)
-	assert.Equal(t, map[string]json.RawMessage{context + ": " + namespace: kubectlReturn}, output)
+	got := do(kubeContext, namespace, m)
+	got.Duration = 0
+	assert.Equal(t, Result{Context: kubeContext, Namespace: namespace, Stdout: kubectlReturn}, got)
 }
 
 func TestKubectl(t *testing.T) {
@@ -204,17 +200,17 @@ func TestGetLocalArgs(t *testing.T) {
 	}{
 		"default": {
 			args: []string{"get", "pods", "-n", "kube-system"},
-			want: []string{"get", "pods", "-n", "kube-system", "--context", context, "--namespace", namespace},
+			want: []string{"get", "pods", "-n", "kube-system", "--context", kubeContext, "--namespace", namespace},
 		},
 		"exec": {
 			args: []string{"exec", "deployment/local-path-provisioner", "-n", "local-path-storage", "-it", "--", "ls", "/usr"},
-			want: []string{"exec", "deployment/local-path-provisioner", "-n", "local-path-storage", "-it", "--context", context, "--namespace", namespace, "--", "ls", "/usr"},
+			want: []string{"exec", "deployment/local-path-provisioner", "-n", "local-path-storage", "-it", "--context", kubeContext, "--namespace", namespace, "--", "ls", "/usr"},
 		},
 	}
 
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
-			got := getLocalArgs(test.args, context, namespace)
+			got := getLocalArgs(test.args, kubeContext, namespace)
 			assert.Equal(t, test.want, got)
 		})
 	}
@@ -226,6 +222,6 @@ func TestOutputsString(t *testing.T) {
 }
 
 func TestFormatContext(t *testing.T) {
-	got := formatContext(context, namespace, kubectlReturn)
+	got := formatContext(kubeContext, namespace, kubectlReturn)
 	assert.Equal(t, "\nkind-kind: default\n------------------\n"+string(kubectlReturn), got)
 }
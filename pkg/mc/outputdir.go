@@ -0,0 +1,131 @@
+package mc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"text/template"
+	"time"
+
+	"go.uber.org/zap"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	defaultOutputTemplate = "{{.Context}}/{{.Namespace}}.yaml"
+	outputDirIndex        = "index.yaml"
+	outputDirErrorSuffix  = ".error"
+
+	statusSucceeded = "succeeded"
+	statusFailed    = "failed"
+)
+
+// outputFile holds the fields available in the --output-template
+type outputFile struct {
+	Context   string
+	Namespace string
+}
+
+// indexEntry summarizes a single execution in the index file of the output directory
+type indexEntry struct {
+	Context   string `json:"context"`
+	Namespace string `json:"namespace,omitempty"`
+	Status    string `json:"status"`
+	File      string `json:"file"`
+	Error     string `json:"error,omitempty"`
+	CacheAge  string `json:"cacheAge,omitempty"`
+}
+
+// writeOutputDir writes every result into its own file within the output directory, formatted according to the
+// output option. Failed executions are written into an error file instead. An index file lists all executions
+func (mc *MC) writeOutputDir(results []Result) error {
+	tmpl, err := template.New("output").Option("missingkey=error").Parse(mc.OutputTmpl)
+	if err != nil {
+		return err
+	}
+
+	sortResults(results)
+
+	// render all file names before writing anything, so that no execution overwrites the output of another one
+	files := make([]string, len(results))
+	seen := map[string]bool{outputDirIndex: true}
+	for i, r := range results {
+		if files[i], err = outputFileName(tmpl, r.Context, r.Namespace); err != nil {
+			return err
+		}
+		if seen[filepath.Clean(files[i])] {
+			return fmt.Errorf("--output-template renders %s more than once. Use {{.Context}} and {{.Namespace}} in it", files[i])
+		}
+		seen[filepath.Clean(files[i])] = true
+	}
+
+	index := []indexEntry{}
+	for i, r := range results {
+		entry := indexEntry{Context: r.Context, Namespace: r.Namespace, Status: statusSucceeded, File: files[i]}
+		content := r.Stdout
+		if r.Err != nil {
+			entry.Status, entry.Error = statusFailed, r.Err.Error()
+			entry.File += outputDirErrorSuffix
+			content = []byte(r.Err.Error())
+		} else if content, err = mc.formatResult(r); err != nil {
+			logger.Debug("failed to parse output", zap.String("context", r.Context), zap.ByteString("retrieved", r.Stdout))
+			return errCouldntParseOutput
+		}
+		if r.Age > 0 {
+			entry.CacheAge = r.Age.Round(time.Second).String()
+		}
+		if err := writeFile(filepath.Join(mc.OutputDir, entry.File), content); err != nil {
+			return err
+		}
+		index = append(index, entry)
+	}
+
+	i, err := yaml.Marshal(index)
+	if err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(mc.OutputDir, outputDirIndex), i)
+}
+
+// outputFileName renders the output template for a context and namespace.
+// Both are escaped so that they always result in a single path element
+func outputFileName(tmpl *template.Template, context string, namespace string) (string, error) {
+	if namespace == "" {
+		namespace = emptyNamespace
+	}
+	b := bytes.NewBuffer([]byte(``))
+	err := tmpl.Execute(b, outputFile{Context: url.PathEscape(context), Namespace: url.PathEscape(namespace)})
+	return filepath.FromSlash(b.String()), err
+}
+
+// formatResult formats the stdout of a single execution according to the output option
+func (mc *MC) formatResult(r Result) ([]byte, error) {
+	b := bytes.NewBuffer([]byte(``))
+	switch mc.Output {
+	case JSON:
+		if err := json.Indent(b, markCached(r), "", "  "); err != nil {
+			return nil, err
+		}
+		return b.Bytes(), nil
+	case YAML:
+		return yaml.JSONToYAML(markCached(r))
+	case CSV, TSV:
+		if err := mc.writeTable(b, []Result{r}); err != nil {
+			return nil, err
+		}
+		return b.Bytes(), nil
+	}
+	return r.Stdout, nil
+}
+
+// writeFile writes content to path and creates all missing parent directories
+func writeFile(path string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, content, 0644)
+}
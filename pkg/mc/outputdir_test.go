@@ -0,0 +1,110 @@
+package mc
+
+import (
+	"context"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"text/template"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jonnylangefeld/kubectl-mc/pkg/mc/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMC_WriteOutputDir(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	list := mocks.NewMockCmd(ctrl)
+	succeeded := mocks.NewMockCmd(ctrl)
+	failed := mocks.NewMockCmd(ctrl)
+	dir := t.TempDir()
+
+	list.EXPECT().Output().Return([]byte("kind-kind\nkind-kind1\n"), nil)
+	succeeded.EXPECT().Output().Return(kubectlReturnSA, nil)
+	failed.EXPECT().Output().Return(nil, &exec.ExitError{Stderr: []byte("Error: connection refused")})
+
+	mc := New("")
+	mc.getListContextsCmd = func() Cmd {
+		return list
+	}
+	mc.getKubectlCmd = func(ctx context.Context, args []string, c string, namespace string) Cmd {
+		if c == kubeContext {
+			return succeeded
+		}
+		return failed
+	}
+	mc.Cmd.SetOut(ioutil.Discard)
+	mc.Cmd.SetArgs([]string{"-r", "kind", "-o", "yaml", "--output-dir", dir, "--", "get", "sa"})
+	assert.NoError(t, mc.Cmd.Execute())
+
+	got, err := ioutil.ReadFile(filepath.Join(dir, "kind-kind", "_.yaml"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(got), "items:\n- apiVersion: v1\n  kind: ServiceAccount\n")
+
+	got, err = ioutil.ReadFile(filepath.Join(dir, "kind-kind1", "_.yaml.error"))
+	assert.NoError(t, err)
+	assert.Equal(t, "connection refused", string(got))
+
+	got, err = ioutil.ReadFile(filepath.Join(dir, outputDirIndex))
+	assert.NoError(t, err)
+	assert.Equal(t, `- context: kind-kind
+  file: kind-kind/_.yaml
+  status: succeeded
+- context: kind-kind1
+  error: connection refused
+  file: kind-kind1/_.yaml.error
+  status: failed
+`, string(got))
+}
+
+func TestMC_WriteOutputDirDuplicates(t *testing.T) {
+	dir := t.TempDir()
+	mc := New("")
+	mc.OutputDir = dir
+	mc.OutputTmpl = "{{.Context}}.yaml"
+	results := []Result{
+		{Context: kubeContext, Namespace: "default", Stdout: kubectlReturn},
+		{Context: kubeContext, Namespace: "kube-system", Stdout: kubectlReturn},
+	}
+	assert.EqualError(t, mc.writeOutputDir(results), "--output-template renders kind-kind.yaml more than once. Use {{.Context}} and {{.Namespace}} in it")
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, files)
+
+	mc.OutputTmpl = outputDirIndex
+	assert.EqualError(t, mc.writeOutputDir(results[:1]), "--output-template renders index.yaml more than once. Use {{.Context}} and {{.Namespace}} in it")
+}
+
+func TestOutputFileName(t *testing.T) {
+	tmpl := template.Must(template.New("").Parse(defaultOutputTemplate))
+
+	got, err := outputFileName(tmpl, kubeContext, namespace)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join("kind-kind", "default.yaml"), got)
+
+	got, err = outputFileName(tmpl, "arn:aws:eks:eu-west-1:1234:cluster/prod", "")
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join("arn:aws:eks:eu-west-1:1234:cluster%2Fprod", "_.yaml"), got)
+}
+
+func TestMC_FormatResult(t *testing.T) {
+	got, err := (&MC{}).formatResult(Result{Stdout: kubectlReturn})
+	assert.NoError(t, err)
+	assert.Equal(t, kubectlReturn, got)
+
+	got, err = (&MC{Output: YAML}).formatResult(Result{Stdout: kubectlReturnSA})
+	assert.NoError(t, err)
+	assert.Contains(t, string(got), "items:\n- apiVersion: v1\n  kind: ServiceAccount\n")
+
+	got, err = (&MC{Output: JSON}).formatResult(Result{Stdout: kubectlReturnSA})
+	assert.NoError(t, err)
+	assert.Contains(t, string(got), "{\n  \"apiVersion\": \"v1\",\n  \"items\": [\n")
+
+	got, err = (&MC{Output: CSV}).formatResult(Result{Context: kubeContext, Stdout: kubectlReturn})
+	assert.NoError(t, err)
+	assert.Contains(t, string(got), "CONTEXT,NAMESPACE,NAME,READY,STATUS,RESTARTS,AGE\nkind-kind,,coredns-66bff467f8-4lnsg,1/1,Running,0,14h\n")
+
+	_, err = (&MC{Output: JSON}).formatResult(Result{Stdout: kubectlReturn})
+	assert.Error(t, err)
+}
@@ -0,0 +1,272 @@
+package mc
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+const lastSelectionFile = "last-selection"
+
+// keys of the picker in raw terminal mode
+const (
+	keyCtrlA     = 1
+	keyCtrlC     = 3
+	keyBackspace = 8
+	keyTab       = 9
+	keyLineFeed  = 10
+	keyEnter     = 13
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyEscape    = 27
+	keySpace     = 32
+	keyDelete    = 127
+)
+
+var (
+	errPickAndLast       = fmt.Errorf("--pick and --last can't be used together")
+	errPickAborted       = fmt.Errorf("aborted, no contexts picked")
+	errPickNoTerminal    = fmt.Errorf("--pick requires stdin to be a terminal")
+	errNoLastSelection   = fmt.Errorf("there is no last selection. Pick contexts with --pick first")
+	errNoContextSelected = fmt.Errorf("none of the contexts of the last selection exist anymore")
+)
+
+// picker is an interactive multi-select list with a fuzzy filter
+type picker struct {
+	items    []string
+	selected map[string]bool
+	filter   []rune
+	cursor   int
+}
+
+// pick lets the user select from contexts interactively and saves the selection as last selection
+func (mc *MC) pick(contexts []string) ([]string, error) {
+	p := &picker{items: contexts, selected: map[string]bool{}}
+	// the last selection is preselected, so it can be adjusted easily
+	if last, err := readLastSelection(); err == nil {
+		for _, c := range last {
+			p.selected[c] = true
+		}
+	}
+
+	restore, err := mc.makeRaw()
+	if err != nil {
+		return nil, err
+	}
+	picked, err := p.run(mc.Cmd.InOrStdin(), mc.Cmd.ErrOrStderr())
+	restore()
+	if err != nil {
+		return nil, err
+	}
+	return picked, writeLastSelection(picked)
+}
+
+// lastSelection returns the contexts of the last selection that still exist
+func lastSelection(contexts []string) ([]string, error) {
+	selection, err := readLastSelection()
+	if os.IsNotExist(err) {
+		return nil, errNoLastSelection
+	}
+	if err != nil {
+		return nil, err
+	}
+	var picked []string
+	for _, c := range contexts {
+		if contains(selection, c) {
+			picked = append(picked, c)
+		}
+	}
+	if len(picked) == 0 {
+		return nil, errNoContextSelected
+	}
+	return picked, nil
+}
+
+// readLastSelection reads the contexts of the last selection from the state dir
+func readLastSelection() ([]string, error) {
+	b, err := ioutil.ReadFile(filepath.Join(stateDir(), lastSelectionFile))
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(b)), nil
+}
+
+// writeLastSelection saves contexts as last selection into the state dir, one context per line
+func writeLastSelection(contexts []string) error {
+	return writeFile(filepath.Join(stateDir(), lastSelectionFile), []byte(strings.Join(contexts, "\n")+"\n"))
+}
+
+// makeRaw puts the terminal of stdin into raw mode via stty, so the picker receives every key press. The returned
+// function restores the previous mode
+func makeRaw() (func(), error) {
+	if !isTerminal(os.Stdin) {
+		return nil, errPickNoTerminal
+	}
+	stty := func(args ...string) ([]byte, error) {
+		cmd := exec.Command("stty", args...)
+		cmd.Stdin = os.Stdin
+		return cmd.Output()
+	}
+	state, err := stty("-g")
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read terminal state: %v", err)
+	}
+	if _, err := stty("raw", "-echo"); err != nil {
+		return nil, fmt.Errorf("couldn't switch terminal into raw mode: %v", err)
+	}
+	return func() {
+		stty(strings.TrimSpace(string(state)))
+	}, nil
+}
+
+// run reads key presses from in and redraws the picker on out after every key, until the selection is confirmed with
+// enter. If nothing is selected, the context under the cursor is picked
+func (p *picker) run(in io.Reader, out io.Writer) ([]string, error) {
+	r := bufio.NewReader(in)
+	for {
+		p.render(out)
+		key, _, err := r.ReadRune()
+		if err == io.EOF {
+			return nil, errPickAborted
+		}
+		if err != nil {
+			return nil, err
+		}
+		visible := p.visible()
+		switch key {
+		case keyCtrlC:
+			p.clear(out)
+			return nil, errPickAborted
+		case keyEnter, keyLineFeed:
+			p.clear(out)
+			picked := p.picked()
+			if len(picked) == 0 && len(visible) > 0 {
+				picked = []string{visible[p.cursor]}
+			}
+			if len(picked) == 0 {
+				return nil, errPickAborted
+			}
+			return picked, nil
+		case keySpace, keyTab:
+			if len(visible) > 0 {
+				c := visible[p.cursor]
+				p.selected[c] = !p.selected[c]
+				p.move(1)
+			}
+		case keyCtrlA:
+			all := true
+			for _, c := range visible {
+				all = all && p.selected[c]
+			}
+			for _, c := range visible {
+				p.selected[c] = !all
+			}
+		case keyCtrlN:
+			p.move(1)
+		case keyCtrlP:
+			p.move(-1)
+		case keyEscape:
+			// arrow keys are sent as escape sequences like ESC [ A, which arrive with a single read. Waiting for more
+			// bytes would block on a lone ESC, so that aborts
+			if r.Buffered() == 0 {
+				p.clear(out)
+				return nil, errPickAborted
+			}
+			if b, err := r.Peek(2); err == nil && b[0] == '[' {
+				r.Discard(2)
+				switch b[1] {
+				case 'A':
+					p.move(-1)
+				case 'B':
+					p.move(1)
+				}
+			}
+		case keyBackspace, keyDelete:
+			if len(p.filter) > 0 {
+				p.filter = p.filter[:len(p.filter)-1]
+				p.cursor = 0
+			}
+		default:
+			if unicode.IsPrint(key) {
+				p.filter = append(p.filter, key)
+				p.cursor = 0
+			}
+		}
+	}
+}
+
+// visible returns all items matching the filter
+func (p *picker) visible() (visible []string) {
+	for _, item := range p.items {
+		if fuzzyMatch(item, string(p.filter)) {
+			visible = append(visible, item)
+		}
+	}
+	return
+}
+
+// picked returns all selected items in their original order
+func (p *picker) picked() (picked []string) {
+	for _, item := range p.items {
+		if p.selected[item] {
+			picked = append(picked, item)
+		}
+	}
+	return
+}
+
+// move moves the cursor by n lines within the visible items
+func (p *picker) move(n int) {
+	p.cursor += n
+	if max := len(p.visible()) - 1; p.cursor > max {
+		p.cursor = max
+	}
+	if p.cursor < 0 {
+		p.cursor = 0
+	}
+}
+
+// render draws the filter and all visible items. Lines end with \r\n, as the terminal is in raw mode
+func (p *picker) render(out io.Writer) {
+	p.clear(out)
+	fmt.Fprintf(out, "pick contexts (space: toggle, ctrl-a: toggle all, enter: confirm, esc: abort) %d/%d selected\r\n", len(p.picked()), len(p.items))
+	fmt.Fprintf(out, "> %s\r\n", string(p.filter))
+	for i, item := range p.visible() {
+		cursor, check := " ", " "
+		if i == p.cursor {
+			cursor = ">"
+		}
+		if p.selected[item] {
+			check = "x"
+		}
+		fmt.Fprintf(out, "%s [%s] %s\r\n", cursor, check, item)
+	}
+}
+
+// clear clears the terminal and moves the cursor to the top left
+func (p *picker) clear(out io.Writer) {
+	fmt.Fprint(out, "\x1b[H\x1b[2J")
+}
+
+// fuzzyMatch returns true if all characters of filter appear in s in the same order, ignoring case
+func fuzzyMatch(s string, filter string) bool {
+	f := []rune(strings.ToLower(filter))
+	if len(f) == 0 {
+		return true
+	}
+	for _, r := range strings.ToLower(s) {
+		if r == f[0] {
+			f = f[1:]
+			if len(f) == 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
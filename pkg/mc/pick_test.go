@@ -0,0 +1,135 @@
+package mc
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jonnylangefeld/kubectl-mc/pkg/mc/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPicker_Run(t *testing.T) {
+	items := []string{"kind-kind", "kind-kind1", "prod-eu", "prod-us"}
+
+	tests := map[string]struct {
+		keys     string
+		selected []string
+		want     []string
+		wantErr  error
+	}{
+		"enter picks the cursor": {
+			keys: "\r",
+			want: []string{"kind-kind"},
+		},
+		"toggle with space": {
+			keys: "  \r",
+			want: []string{"kind-kind", "kind-kind1"},
+		},
+		"arrow keys": {
+			keys: "\x1b[B\x1b[B\x1b[A\x1b[B \r",
+			want: []string{"prod-eu"},
+		},
+		"fuzzy filter": {
+			keys: "pdus\r",
+			want: []string{"prod-us"},
+		},
+		"backspace": {
+			keys: "pdx\x7f\x7f\x01\r",
+			want: []string{"prod-eu", "prod-us"},
+		},
+		"toggle all visible": {
+			keys:     "prod\x01\x01\r",
+			selected: []string{"kind-kind"},
+			want:     []string{"kind-kind"},
+		},
+		"preselected": {
+			keys:     "\x0e\t\r",
+			selected: []string{"prod-us"},
+			want:     []string{"kind-kind1", "prod-us"},
+		},
+		"ctrl-c": {
+			keys:    "  \x03",
+			wantErr: errPickAborted,
+		},
+		"esc": {
+			keys:    " \x1b",
+			wantErr: errPickAborted,
+		},
+		"no match": {
+			keys:    "xyz\r",
+			wantErr: errPickAborted,
+		},
+		"eof": {
+			keys:    " ",
+			wantErr: errPickAborted,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := &picker{items: items, selected: map[string]bool{}}
+			for _, s := range test.selected {
+				p.selected[s] = true
+			}
+			got, err := p.run(strings.NewReader(test.keys), ioutil.Discard)
+			assert.Equal(t, test.wantErr, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestPicker_Render(t *testing.T) {
+	p := &picker{items: []string{"kind-kind", "kind-kind1", "prod-eu"}, selected: map[string]bool{"kind-kind1": true}, filter: []rune("kind"), cursor: 1}
+	b := bytes.NewBuffer([]byte(``))
+	p.render(b)
+	assert.Equal(t, "\x1b[H\x1b[2Jpick contexts (space: toggle, ctrl-a: toggle all, enter: confirm, esc: abort) 1/3 selected\r\n> kind\r\n  [ ] kind-kind\r\n> [x] kind-kind1\r\n", b.String())
+}
+
+func TestFuzzyMatch(t *testing.T) {
+	assert.True(t, fuzzyMatch("kind-kind", ""))
+	assert.True(t, fuzzyMatch("kind-kind", "kk"))
+	assert.True(t, fuzzyMatch("Prod-EU", "peu"))
+	assert.False(t, fuzzyMatch("kind-kind", "kx"))
+	assert.False(t, fuzzyMatch("kind", "kindd"))
+}
+
+func TestMC_PickAndLast(t *testing.T) {
+	os.Remove(filepath.Join(stateDir(), lastSelectionFile))
+	ctrl := gomock.NewController(t)
+	m := mocks.NewMockCmd(ctrl)
+	m.EXPECT().Output().Return([]byte("kind-kind\nkind-kind1\nkind-kind2\n"), nil).Times(2)
+
+	newMC := func() (*MC, *bytes.Buffer) {
+		mc := New("")
+		mc.getListContextsCmd = func() Cmd {
+			return m
+		}
+		mc.makeRaw = func() (func(), error) {
+			return func() {}, nil
+		}
+		b := bytes.NewBuffer([]byte(``))
+		mc.Cmd.SetOut(b)
+		mc.Cmd.SetErr(ioutil.Discard)
+		return mc, b
+	}
+
+	mc, b := newMC()
+	mc.Cmd.SetIn(strings.NewReader("\x0e  \r"))
+	mc.Cmd.SetArgs([]string{"--pick", "-l"})
+	assert.NoError(t, mc.Cmd.Execute())
+	assert.Equal(t, "kind-kind1\nkind-kind2\n", b.String())
+
+	mc, b = newMC()
+	mc.Cmd.SetArgs([]string{"--last", "-r", "kind-kind[12]?$", "-x", "2", "-l"})
+	assert.NoError(t, mc.Cmd.Execute())
+	assert.Equal(t, "kind-kind1\n", b.String())
+
+	mc, _ = newMC()
+	mc.Cmd.SetArgs([]string{"--pick", "--last", "-l"})
+	assert.Equal(t, errPickAndLast, mc.Cmd.Execute())
+}
@@ -0,0 +1,102 @@
+package mc
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	planFormatText  = "text"
+	planFormatShell = "shell"
+)
+
+var errUnknownPlanFormat = fmt.Errorf("this plan format is unknown. Choose one of %s|%s", planFormatText, planFormatShell)
+
+// plannedCommand is a kubectl command that would be executed against a context and namespace
+type plannedCommand struct {
+	context   string
+	namespace string
+	argv      []string
+}
+
+// plan returns the kubectl commands that would be executed against every namespace of the given contexts, with the
+// args rendered and the context and namespace flags injected exactly as in a real run. The commands are in the order
+// they would be started in. A get that lists all namespaces once per context is planned as a single command per context
+func (mc *MC) plan(contexts []string, args []string) ([]plannedCommand, error) {
+	namespaces := strings.Split(mc.Namespaces, ",")
+	if mc.listAllNamespaces(args) {
+		namespaces = []string{""}
+		args = append(append([]string{}, args...), "--all-namespaces")
+	}
+	var commands []plannedCommand
+	for _, j := range schedule(mc.prioritize(contexts), namespaces) {
+		rendered, err := mc.renderArgs(args, j.context, j.namespace)
+		if err != nil {
+			return nil, err
+		}
+		argv := append([]string{"kubectl"}, getLocalArgs(rendered, j.context, j.namespace)...)
+		commands = append(commands, plannedCommand{context: j.context, namespace: j.namespace, argv: argv})
+	}
+	return commands, nil
+}
+
+// concurrency returns the max amount of commands the runner would execute in parallel
+func (mc *MC) concurrency(commands []plannedCommand) int {
+	opts := mc.runner(nil).opts
+	perContext := map[string]int{}
+	for _, c := range commands {
+		perContext[c.context]++
+	}
+	concurrency := 0
+	for _, n := range perContext {
+		if opts.MaxProcPerContext > 0 && n > opts.MaxProcPerContext {
+			n = opts.MaxProcPerContext
+		}
+		concurrency += n
+	}
+	if concurrency > opts.MaxProc {
+		concurrency = opts.MaxProc
+	}
+	return concurrency
+}
+
+// printArgs prints the kubectl command line for every context and namespace
+func (mc *MC) printArgs(contexts []string, args []string) error {
+	commands, err := mc.plan(contexts, args)
+	if err != nil {
+		return err
+	}
+	for _, c := range commands {
+		fmt.Fprintln(mc.Cmd.OutOrStdout(), shellJoin(c.argv))
+	}
+	return nil
+}
+
+// printPlan prints the execution plan of a run without executing anything. The text format lists every command with
+// its context and namespace, the shell format is a script that executes all commands one after another
+func (mc *MC) printPlan(contexts []string, args []string) error {
+	commands, err := mc.plan(contexts, args)
+	if err != nil {
+		return err
+	}
+	summary := fmt.Sprintf("%d kubectl invocations against %d contexts, %d in parallel", len(commands), len(contexts), mc.concurrency(commands))
+
+	out := mc.Cmd.OutOrStdout()
+	switch mc.PlanFormat {
+	case planFormatShell:
+		fmt.Fprintf(out, "#!/bin/sh\n# %s\n", summary)
+		for _, c := range commands {
+			fmt.Fprintln(out, shellJoin(c.argv))
+		}
+	default:
+		fmt.Fprintln(out, summary)
+		w := tabwriter.NewWriter(out, 0, 8, 3, ' ', 0)
+		fmt.Fprintln(w, "\nCONTEXT\tNAMESPACE\tCOMMAND")
+		for _, c := range commands {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", c.context, c.namespace, shellJoin(c.argv))
+		}
+		return w.Flush()
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package mc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jonnylangefeld/kubectl-mc/pkg/mc/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMC_Plan(t *testing.T) {
+	tests := map[string]struct {
+		args      []string
+		durations map[string]time.Duration
+		want      string
+		wantErr   error
+	}{
+		"text": {
+			args: []string{"-r", "kind", "-n", "default,kube-system", "-p", "3", "--plan", "--", "exec", "deploy/x", "--", "ls", "/usr"},
+			want: `4 kubectl invocations against 2 contexts, 3 in parallel
+
+CONTEXT      NAMESPACE     COMMAND
+kind-kind    default       kubectl exec deploy/x --context kind-kind --namespace default -- ls /usr
+kind-kind1   default       kubectl exec deploy/x --context kind-kind1 --namespace default -- ls /usr
+kind-kind    kube-system   kubectl exec deploy/x --context kind-kind --namespace kube-system -- ls /usr
+kind-kind1   kube-system   kubectl exec deploy/x --context kind-kind1 --namespace kube-system -- ls /usr
+`,
+		},
+		"per context limit": {
+			args: []string{"-r", "kind-kind$", "-n", "w,x,y,z", "-p", "10", "--per-context-processes", "1", "--plan", "--", "delete", "pod", "p"},
+			want: `4 kubectl invocations against 1 contexts, 1 in parallel
+
+CONTEXT     NAMESPACE   COMMAND
+kind-kind   w           kubectl delete pod p --context kind-kind --namespace w
+kind-kind   x           kubectl delete pod p --context kind-kind --namespace x
+kind-kind   y           kubectl delete pod p --context kind-kind --namespace y
+kind-kind   z           kubectl delete pod p --context kind-kind --namespace z
+`,
+		},
+		"slowest context first": {
+			args:      []string{"-r", "kind", "--plan", "--", "get", "pods"},
+			durations: map[string]time.Duration{kubeContext: time.Second, "kind-kind1": time.Minute},
+			want: `2 kubectl invocations against 2 contexts, 2 in parallel
+
+CONTEXT      NAMESPACE   COMMAND
+kind-kind1               kubectl get pods --context kind-kind1
+kind-kind                kubectl get pods --context kind-kind
+`,
+		},
+		"shell": {
+			args: []string{"-r", "kind", "--plan", "--plan-format", "shell", "-o", "json", "--", "delete", "pod", "-l", "app in (a,b)"},
+			want: `#!/bin/sh
+# 2 kubectl invocations against 2 contexts, 2 in parallel
+kubectl delete pod -l 'app in (a,b)' -o json --context kind-kind
+kubectl delete pod -l 'app in (a,b)' -o json --context kind-kind1
+`,
+		},
+		"all namespaces": {
+			args: []string{"-r", "kind", "-n", "default,kube-system", "--plan", "-o", "json", "--", "get", "pods"},
+			want: `2 kubectl invocations against 2 contexts, 2 in parallel
+
+CONTEXT      NAMESPACE   COMMAND
+kind-kind                kubectl get pods -o json --all-namespaces --context kind-kind
+kind-kind1               kubectl get pods -o json --all-namespaces --context kind-kind1
+`,
+		},
+		"unknown format": {
+			args:    []string{"-r", "kind", "--plan", "--plan-format", "foo", "--", "get", "pods"},
+			wantErr: errUnknownPlanFormat,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			m := mocks.NewMockCmd(ctrl)
+			m.EXPECT().Output().Return([]byte("kind-kind\nkind-kind1\n"), nil).AnyTimes()
+			mc := New("")
+			mc.getListContextsCmd = func() Cmd {
+				return m
+			}
+			mc.getKubectlCmd = func(ctx context.Context, args []string, c string, namespace string) Cmd {
+				t.Fatal("no kubectl command must be executed")
+				return nil
+			}
+			mc.DurationsFile = ""
+			if test.durations != nil {
+				mc.DurationsFile = filepath.Join(t.TempDir(), durationsFile)
+				b, err := json.Marshal(test.durations)
+				assert.NoError(t, err)
+				assert.NoError(t, ioutil.WriteFile(mc.DurationsFile, b, 0644))
+			}
+			b := bytes.NewBuffer([]byte(``))
+			mc.Cmd.SetOut(b)
+			mc.Cmd.SetErr(ioutil.Discard)
+			mc.Cmd.SetArgs(test.args)
+			err := mc.Cmd.Execute()
+			if test.wantErr != nil {
+				assert.Equal(t, test.wantErr, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.want, b.String())
+		})
+	}
+}
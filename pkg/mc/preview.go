@@ -0,0 +1,145 @@
+package mc
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+)
+
+var (
+	// dryRunVerbs are the kubectl verbs that support --dry-run=server
+	dryRunVerbs = map[string]bool{
+		"annotate":  true,
+		"apply":     true,
+		"autoscale": true,
+		"create":    true,
+		"delete":    true,
+		"expose":    true,
+		"label":     true,
+		"patch":     true,
+		"replace":   true,
+		"run":       true,
+		"scale":     true,
+		"set":       true,
+		"taint":     true,
+	}
+
+	// dryRunLine matches a line of kubectl output in server dry run mode, like
+	// `deployment.apps/app configured (server dry run)` or `pod "debug" force deleted (server dry run)`
+	dryRunLine = regexp.MustCompile(`^\S+(?: "[^"]*")? (.+?) \(server dry run\)$`)
+
+	errPreviewUnsupported = fmt.Errorf("--preview is only supported for the kubectl verbs apply, annotate, autoscale, create, delete, expose, label, patch, replace, run, scale, set and taint")
+	errPreviewAborted     = fmt.Errorf("aborted")
+)
+
+// previewArgs returns the args to preview the changes of args. For apply this is kubectl diff, for all other verbs
+// the same command in server dry run mode
+func previewArgs(args []string) ([]string, error) {
+	verb := kubectlVerb(args)
+	if !dryRunVerbs[verb] {
+		return nil, errPreviewUnsupported
+	}
+	var preview []string
+	injected := false
+	for _, arg := range args {
+		if arg == verb && verb == "apply" && !injected {
+			preview = append(preview, "diff")
+			injected = true
+			continue
+		}
+		if arg == "--" && !injected {
+			preview = append(preview, "--dry-run=server")
+			injected = true
+		}
+		preview = append(preview, arg)
+	}
+	if !injected {
+		preview = append(preview, "--dry-run=server")
+	}
+	return preview, nil
+}
+
+// preview runs the preview of args against every namespace of the given contexts, prints a summary of the changes
+// per context and asks for confirmation. It returns true if the real command should be executed
+func (mc *MC) preview(ctx context.Context, contexts []string, args []string) (bool, error) {
+	pArgs, err := previewArgs(args)
+	if err != nil {
+		return false, err
+	}
+	results, err := mc.runner(nil).RunContexts(ctx, contexts, pArgs)
+	if err != nil {
+		return false, err
+	}
+
+	out := mc.Cmd.ErrOrStderr()
+	w := tabwriter.NewWriter(out, 0, 8, 3, ' ', 0)
+	fmt.Fprintln(w, "CONTEXT\tNAMESPACE\tCHANGES")
+	for _, r := range results {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", r.Context, r.Namespace, previewSummary(r, kubectlVerb(args) == "apply"))
+	}
+	if err := w.Flush(); err != nil {
+		return false, err
+	}
+
+	fmt.Fprintf(out, "\nRun `kubectl %s` against %d contexts? [y/N]: ", strings.Join(args, " "), len(contexts))
+	if !confirmed(mc.Cmd.InOrStdin()) {
+		return false, errPreviewAborted
+	}
+	return true, nil
+}
+
+// previewSummary summarizes the changes of a single preview result. kubectl diff exits with 1 if there are changes,
+// every other non-zero exit code is an error
+func previewSummary(r Result, diff bool) string {
+	if diff {
+		if r.Err != nil && r.ExitCode != 1 {
+			return "failed: " + firstLine(r.Err.Error())
+		}
+		changed := strings.Count(string(r.Stdout), "diff -u -N ")
+		if changed == 0 {
+			return "no changes"
+		}
+		return fmt.Sprintf("%d objects changed", changed)
+	}
+
+	if r.Err != nil {
+		return "failed: " + firstLine(r.Err.Error())
+	}
+	var actions []string
+	counts := map[string]int{}
+	s := bufio.NewScanner(strings.NewReader(string(r.Stdout)))
+	for s.Scan() {
+		m := dryRunLine.FindStringSubmatch(strings.TrimSpace(s.Text()))
+		if m == nil {
+			continue
+		}
+		if counts[m[1]] == 0 {
+			actions = append(actions, m[1])
+		}
+		counts[m[1]]++
+	}
+	if len(actions) == 0 {
+		return "no changes"
+	}
+	summary := make([]string, 0, len(actions))
+	for _, a := range actions {
+		summary = append(summary, fmt.Sprintf("%d %s", counts[a], a))
+	}
+	return strings.Join(summary, ", ")
+}
+
+// confirmed reads a line from in and returns true if it is a yes
+func confirmed(in io.Reader) bool {
+	answer, _ := bufio.NewReader(in).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// firstLine returns the first line of s
+func firstLine(s string) string {
+	return strings.SplitN(strings.TrimSpace(s), "\n", 2)[0]
+}
@@ -0,0 +1,135 @@
+package mc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jonnylangefeld/kubectl-mc/pkg/mc/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMC_Preview(t *testing.T) {
+	tests := map[string]struct {
+		stdin      string
+		wantRun    bool
+		wantErr    error
+		wantStderr string
+	}{
+		"confirmed": {
+			stdin:   "y\n",
+			wantRun: true,
+			wantStderr: `CONTEXT      NAMESPACE   CHANGES
+kind-kind                1 scaled
+kind-kind1               1 scaled
+
+Run ` + "`kubectl scale deploy/app --replicas 3`" + ` against 2 contexts? [y/N]: `,
+		},
+		"aborted": {
+			stdin:   "\n",
+			wantErr: errPreviewAborted,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			list := mocks.NewMockCmd(ctrl)
+			dryRun := mocks.NewMockCmd(ctrl)
+			run := mocks.NewMockCmd(ctrl)
+			list.EXPECT().Output().Return([]byte("kind-kind\nkind-kind1\n"), nil)
+			dryRun.EXPECT().Output().Return([]byte("deployment.apps/app scaled (server dry run)\n"), nil).Times(2)
+			if test.wantRun {
+				run.EXPECT().Output().Return([]byte("deployment.apps/app scaled\n"), nil).Times(2)
+			}
+
+			mc := New("")
+			mc.getListContextsCmd = func() Cmd {
+				return list
+			}
+			mc.getKubectlCmd = func(ctx context.Context, args []string, c string, namespace string) Cmd {
+				if args[len(args)-1] == "--dry-run=server" {
+					return dryRun
+				}
+				return run
+			}
+			stdout := bytes.NewBuffer([]byte(``))
+			stderr := bytes.NewBuffer([]byte(``))
+			mc.Cmd.SetOut(stdout)
+			mc.Cmd.SetErr(stderr)
+			mc.Cmd.SetIn(strings.NewReader(test.stdin))
+			mc.Cmd.SetArgs([]string{"-r", "kind", "--preview", "--", "scale", "deploy/app", "--replicas", "3"})
+			err := mc.Cmd.Execute()
+			assert.Equal(t, test.wantErr, err)
+			if test.wantRun {
+				assert.Equal(t, test.wantStderr, stderr.String())
+				assert.Contains(t, stdout.String(), "\nkind-kind1\n----------\ndeployment.apps/app scaled\n")
+			} else {
+				assert.Empty(t, stdout.String())
+			}
+		})
+	}
+}
+
+func TestMC_PreviewUnsupported(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	m := mocks.NewMockCmd(ctrl)
+	m.EXPECT().Output().Return([]byte("kind-kind\n"), nil)
+
+	mc := New("")
+	mc.getListContextsCmd = func() Cmd {
+		return m
+	}
+	mc.Cmd.SetOut(ioutil.Discard)
+	mc.Cmd.SetErr(ioutil.Discard)
+	mc.Cmd.SetArgs([]string{"--preview", "--", "get", "pods"})
+	assert.Equal(t, errPreviewUnsupported, mc.Cmd.Execute())
+}
+
+func TestPreviewArgs(t *testing.T) {
+	tests := map[string]struct {
+		args []string
+		want []string
+	}{
+		"apply": {
+			args: []string{"apply", "-f", "apply/"},
+			want: []string{"diff", "-f", "apply/"},
+		},
+		"patch": {
+			args: []string{"patch", "deploy", "app", "-p", `{"spec":{}}`},
+			want: []string{"patch", "deploy", "app", "-p", `{"spec":{}}`, "--dry-run=server"},
+		},
+		"run": {
+			args: []string{"run", "debug", "--image", "busybox", "--", "sleep", "infinity"},
+			want: []string{"run", "debug", "--image", "busybox", "--dry-run=server", "--", "sleep", "infinity"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := previewArgs(test.args)
+			assert.NoError(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestPreviewSummary(t *testing.T) {
+	assert.Equal(t, "2 configured, 1 unchanged", previewSummary(Result{Stdout: []byte(`deployment.apps/a configured (server dry run)
+service/a unchanged (server dry run)
+deployment.apps/b configured (server dry run)
+`)}, false))
+	assert.Equal(t, "2 deleted, 1 force deleted", previewSummary(Result{Stdout: []byte(`pod "debug" deleted (server dry run)
+pod "debug-2" deleted (server dry run)
+pod "stuck" force deleted (server dry run)
+`)}, false))
+	assert.Equal(t, "no changes", previewSummary(Result{}, false))
+	assert.Equal(t, "failed: forbidden", previewSummary(Result{Err: fmt.Errorf("forbidden\nmore details"), ExitCode: 1}, false))
+	assert.Equal(t, "2 objects changed", previewSummary(Result{Stdout: []byte("diff -u -N /tmp/a /tmp/b\n...\ndiff -u -N /tmp/c /tmp/d\n"), Err: fmt.Errorf("exit status 1"), ExitCode: 1}, true))
+	assert.Equal(t, "no changes", previewSummary(Result{}, true))
+	assert.Equal(t, "failed: unable to recognize", previewSummary(Result{Err: fmt.Errorf("unable to recognize"), ExitCode: 2}, true))
+}
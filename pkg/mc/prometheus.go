@@ -0,0 +1,177 @@
+package mc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Prometheus represents the string for the prometheus text format
+const Prometheus = "prometheus"
+
+var (
+	metricName = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+	errMetricsNeedJSON = fmt.Errorf("--metric reads the output as json. Use a structured output like -o json, -o yaml or -o prometheus or pass -o json to kubectl")
+	errInvalidMetric   = fmt.Errorf("invalid metric. Use the format NAME:JSONPATH like ready_replicas:.items[*].status.readyReplicas, where NAME is a valid prometheus metric name")
+)
+
+// gauge is a custom metric, whose value is the sum of all values its jsonpath matches in the output
+type gauge struct {
+	name string
+	path string
+}
+
+// metricFamily is a gauge with one sample per context and namespace
+type metricFamily struct {
+	name    string
+	help    string
+	samples []sample
+}
+
+// sample is the value of a metric for a result
+type sample struct {
+	result Result
+	value  float64
+}
+
+// parseMetrics parses custom metrics in the format NAME:JSONPATH
+func parseMetrics(metrics []string) (gauges []gauge, err error) {
+	for _, m := range metrics {
+		parts := strings.SplitN(m, ":", 2)
+		if len(parts) != 2 || !metricName.MatchString(parts[0]) || parts[1] == "" {
+			return nil, errInvalidMetric
+		}
+		gauges = append(gauges, gauge{name: parts[0], path: parts[1]})
+	}
+	return
+}
+
+// jsonOutput returns true if args make kubectl print json
+func jsonOutput(args []string) bool {
+	for i, arg := range args {
+		if arg == "-o=json" || arg == "--output=json" || (arg == "-o" || arg == "--output") && i+1 < len(args) && args[i+1] == "json" {
+			return true
+		}
+	}
+	return false
+}
+
+// metrics returns the metric families of the results: the success and duration of every execution, the number of
+// items of every list and the custom gauges
+func metrics(results []Result, gauges []gauge) []metricFamily {
+	success := metricFamily{name: "mc_execution_success", help: "Whether kubectl succeeded against the context and namespace."}
+	duration := metricFamily{name: "mc_execution_duration_seconds", help: "Duration of the kubectl execution against the context and namespace."}
+	items := metricFamily{name: "mc_items", help: "Number of items returned by a list command."}
+	custom := make([]metricFamily, len(gauges))
+	for i, g := range gauges {
+		custom[i] = metricFamily{name: g.name, help: fmt.Sprintf("Sum of %s.", g.path)}
+	}
+
+	for _, r := range results {
+		s := 0.0
+		if r.Err == nil {
+			s = 1
+		}
+		success.samples = append(success.samples, sample{result: r, value: s})
+		duration.samples = append(duration.samples, sample{result: r, value: r.Duration.Seconds()})
+
+		var obj map[string]interface{}
+		if r.Err != nil || json.Unmarshal(r.Stdout, &obj) != nil {
+			continue
+		}
+		if list, ok := obj["items"].([]interface{}); ok {
+			items.samples = append(items.samples, sample{result: r, value: float64(len(list))})
+		}
+		for i, g := range gauges {
+			if v, ok := sum(obj, g.path); ok {
+				custom[i].samples = append(custom[i].samples, sample{result: r, value: v})
+			}
+		}
+	}
+	return append([]metricFamily{success, duration, items}, custom...)
+}
+
+// sum returns the sum of all numbers, numeric strings and booleans path matches in obj. It is false if no value could
+// be summed up
+func sum(obj interface{}, path string) (float64, bool) {
+	values, err := jsonPath(obj, path)
+	if err != nil {
+		logger.Debug("couldn't evaluate metric", zap.String("jsonpath", path), zap.Error(err))
+		return 0, false
+	}
+	total, found := 0.0, false
+	for _, v := range values {
+		switch v := v.(type) {
+		case float64:
+			total, found = total+v, true
+		case bool:
+			if v {
+				total++
+			}
+			found = true
+		case string:
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				total, found = total+f, true
+			}
+		}
+	}
+	return total, found
+}
+
+// writeMetrics writes the metrics of the results in the prometheus text format, as read by the textfile collector of
+// the node exporter
+func writeMetrics(out io.Writer, results []Result, gauges []gauge) error {
+	for _, m := range metrics(results, gauges) {
+		if len(m.samples) == 0 {
+			continue
+		}
+		if _, err := fmt.Fprintf(out, "# HELP %s %s\n# TYPE %s gauge\n", m.name, m.help, m.name); err != nil {
+			return err
+		}
+		for _, s := range m.samples {
+			value := strconv.FormatFloat(s.value, 'g', -1, 64)
+			if _, err := fmt.Fprintf(out, "%s{%s} %s\n", m.name, labels(s.result), value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeMetricsFile writes the metrics to path. The file is replaced atomically, so the node exporter never reads a
+// partially written file
+func writeMetricsFile(path string, results []Result, gauges []gauge) error {
+	var b bytes.Buffer
+	if err := writeMetrics(&b, results, gauges); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b.Bytes(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// labels returns the labels of the samples of a result. Results served from the cache are labeled with their age
+func labels(r Result) string {
+	l := fmt.Sprintf("context=%s,namespace=%s", labelValue(r.Context), labelValue(r.Namespace))
+	if r.Age > 0 {
+		l += fmt.Sprintf(",cache_age_seconds=%s", labelValue(strconv.FormatFloat(r.Age.Round(time.Second).Seconds(), 'g', -1, 64)))
+	}
+	return l
+}
+
+// labelValue quotes a label value, escaping backslashes, double quotes and line feeds
+func labelValue(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
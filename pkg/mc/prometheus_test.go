@@ -0,0 +1,145 @@
+package mc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jonnylangefeld/kubectl-mc/pkg/mc/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMetrics(t *testing.T) {
+	tests := map[string]struct {
+		metrics []string
+		want    []gauge
+		wantErr error
+	}{
+		"none": {},
+		"valid": {
+			metrics: []string{"ready_replicas:.items[*].status.readyReplicas", "nodes:.items[*].metadata.name"},
+			want:    []gauge{{name: "ready_replicas", path: ".items[*].status.readyReplicas"}, {name: "nodes", path: ".items[*].metadata.name"}},
+		},
+		"invalid name": {
+			metrics: []string{"ready-replicas:.items[*].status.readyReplicas"},
+			wantErr: errInvalidMetric,
+		},
+		"missing path": {
+			metrics: []string{"ready_replicas"},
+			wantErr: errInvalidMetric,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := parseMetrics(test.metrics)
+			assert.Equal(t, test.wantErr, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestWriteMetrics(t *testing.T) {
+	results := []Result{
+		{Context: kubeContext, Namespace: namespace, Stdout: []byte(`{"kind":"List","items":[{"status":{"readyReplicas":2}},{"status":{"readyReplicas":3}}]}`), Duration: 1500 * time.Millisecond},
+		{Context: `kind-"kind"`, Err: fmt.Errorf("forbidden"), ExitCode: 1, Duration: time.Second},
+	}
+	gauges := []gauge{{name: "ready_replicas", path: ".items[*].status.readyReplicas"}}
+
+	b := bytes.NewBuffer([]byte(``))
+	assert.NoError(t, writeMetrics(b, results, gauges))
+	assert.Equal(t, `# HELP mc_execution_success Whether kubectl succeeded against the context and namespace.
+# TYPE mc_execution_success gauge
+mc_execution_success{context="kind-kind",namespace="default"} 1
+mc_execution_success{context="kind-\"kind\"",namespace=""} 0
+# HELP mc_execution_duration_seconds Duration of the kubectl execution against the context and namespace.
+# TYPE mc_execution_duration_seconds gauge
+mc_execution_duration_seconds{context="kind-kind",namespace="default"} 1.5
+mc_execution_duration_seconds{context="kind-\"kind\"",namespace=""} 1
+# HELP mc_items Number of items returned by a list command.
+# TYPE mc_items gauge
+mc_items{context="kind-kind",namespace="default"} 2
+# HELP ready_replicas Sum of .items[*].status.readyReplicas.
+# TYPE ready_replicas gauge
+ready_replicas{context="kind-kind",namespace="default"} 5
+`, b.String())
+}
+
+func TestMC_Prometheus(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	list := mocks.NewMockCmd(ctrl)
+	m := mocks.NewMockCmd(ctrl)
+	list.EXPECT().Output().Return([]byte("kind-kind\n"), nil).Times(2)
+	m.EXPECT().Output().Return([]byte(`{"kind":"List","items":[{"metadata":{"name":"node-1"}}]}`), nil).Times(2)
+	metricsFile := filepath.Join(t.TempDir(), "mc.prom")
+
+	execute := func(args ...string) string {
+		mc := New("")
+		mc.getListContextsCmd = func() Cmd {
+			return list
+		}
+		mc.getKubectlCmd = func(ctx context.Context, args []string, c string, namespace string) Cmd {
+			assert.Equal(t, []string{"get", "nodes", "-o", "json"}, args)
+			return m
+		}
+		b := bytes.NewBuffer([]byte(``))
+		mc.Cmd.SetOut(b)
+		mc.Cmd.SetArgs(args)
+		assert.NoError(t, mc.Cmd.Execute())
+		return b.String()
+	}
+
+	got := execute("-o", "prometheus", "--metric", "nodes:.items[*].metadata.name", "--", "get", "nodes")
+	assert.Contains(t, got, "mc_execution_success{context=\"kind-kind\",namespace=\"\"} 1\n")
+	assert.Contains(t, got, "mc_items{context=\"kind-kind\",namespace=\"\"} 1\n")
+	// names aren't numbers, so the custom gauge has no sample
+	assert.NotContains(t, got, "\nnodes{")
+
+	got = execute("-o", "json", "--metrics-file", metricsFile, "--", "get", "nodes")
+	assert.Contains(t, got, `"kind-kind": {`)
+	b, err := ioutil.ReadFile(metricsFile)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), "mc_items{context=\"kind-kind\",namespace=\"\"} 1\n")
+
+	for _, args := range [][]string{
+		{"--metric", "nodes:.items[*].metadata.name", "--", "get", "nodes"},
+		{"-o", "csv", "--metric", "nodes:.items[*].metadata.name", "--", "get", "nodes"},
+	} {
+		mc := New("")
+		mc.Cmd.SetErr(ioutil.Discard)
+		mc.Cmd.SetArgs(args)
+		assert.Equal(t, errMetricsNeedJSON, mc.Cmd.Execute())
+	}
+}
+
+func TestMC_MetricsFileWithoutJSON(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	list := mocks.NewMockCmd(ctrl)
+	m := mocks.NewMockCmd(ctrl)
+	metricsFile := filepath.Join(t.TempDir(), "mc.prom")
+
+	list.EXPECT().Output().Return([]byte("kind-kind\n"), nil)
+	m.EXPECT().Output().Return([]byte("ok"), nil)
+
+	mc := New("")
+	mc.getListContextsCmd = func() Cmd {
+		return list
+	}
+	mc.getKubectlCmd = func(ctx context.Context, args []string, c string, namespace string) Cmd {
+		assert.Equal(t, []string{"get", "--raw", "/readyz"}, args)
+		return m
+	}
+	b := bytes.NewBuffer([]byte(``))
+	mc.Cmd.SetOut(b)
+	mc.Cmd.SetArgs([]string{"--metrics-file", metricsFile, "--", "get", "--raw", "/readyz"})
+	assert.NoError(t, mc.Cmd.Execute())
+	assert.Contains(t, b.String(), "ok")
+	got, err := ioutil.ReadFile(metricsFile)
+	assert.NoError(t, err)
+	assert.Contains(t, string(got), "mc_execution_success{context=\"kind-kind\",namespace=\"\"} 1\n")
+	assert.NotContains(t, string(got), "mc_items{")
+}
@@ -0,0 +1,192 @@
+package mc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	recordArgv     = "argv.json"
+	recordStdout   = "stdout"
+	recordStderr   = "stderr"
+	recordExitCode = "exit-code"
+)
+
+var (
+	listContextsArgs = []string{"config", "get-contexts", "-o", "name"}
+
+	errRecordAndReplay = fmt.Errorf("--record and --replay can't be used together")
+)
+
+// exitError is returned by a replayed or delegated command that exited with a non-zero exit code.
+// It mirrors the parts of exec.ExitError that are needed to reproduce the original run
+type exitError struct {
+	Stderr []byte
+	Code   int
+}
+
+func (e *exitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// useReplay replaces all commands with the recordings of mc.Replay
+func (mc *MC) useReplay() {
+	mc.getListContextsCmd = func() Cmd {
+		return &replayCmd{dir: listContextsRecordingDir(mc.Replay)}
+	}
+	mc.getConfigViewCmd = func() Cmd {
+		return &replayCmd{dir: configViewRecordingDir(mc.Replay)}
+	}
+	mc.getKubectlCmd = func(ctx context.Context, args []string, kubeContext string, namespace string) Cmd {
+		argv := append([]string{"kubectl"}, getLocalArgs(args, kubeContext, namespace)...)
+		return &replayCmd{dir: recordingDir(mc.Replay, kubeContext, namespace), argv: argv}
+	}
+}
+
+// recordCmd wraps a Cmd and saves the argv, stdout, stderr and exit code of every execution to dir
+type recordCmd struct {
+	cmd  Cmd
+	dir  string
+	argv []string
+}
+
+// Output executes the wrapped command and records its results
+func (r *recordCmd) Output() ([]byte, error) {
+	stdout, err := r.cmd.Output()
+
+	var stderrOut []byte
+	code := 0
+	if err != nil {
+		stderrOut, code = stderr(err), exitCode(err)
+		if stderrOut == nil {
+			stderrOut = []byte(err.Error())
+		}
+	}
+
+	if rerr := writeRecording(r.dir, r.argv, stdout, stderrOut, code); rerr != nil {
+		return stdout, fmt.Errorf("couldn't record execution to %s: %v", r.dir, rerr)
+	}
+	return stdout, err
+}
+
+// replayCmd implements the Cmd interface by reading an execution previously saved by recordCmd. If argv is set, the
+// recording has to be of the same command
+type replayCmd struct {
+	dir  string
+	argv []string
+}
+
+// Output returns the recorded stdout and, for a non-zero exit code, an error carrying the recorded stderr
+func (r *replayCmd) Output() ([]byte, error) {
+	stdout, err := ioutil.ReadFile(filepath.Join(r.dir, recordStdout))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no recording found in %s", r.dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if r.argv != nil {
+		if err := r.checkArgv(); err != nil {
+			return nil, err
+		}
+	}
+	stderr, err := ioutil.ReadFile(filepath.Join(r.dir, recordStderr))
+	if err != nil {
+		return nil, err
+	}
+	c, err := ioutil.ReadFile(filepath.Join(r.dir, recordExitCode))
+	if err != nil {
+		return nil, err
+	}
+	code, err := strconv.Atoi(strings.TrimSpace(string(c)))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't parse recorded exit code in %s: %v", r.dir, err)
+	}
+	if code != 0 {
+		return stdout, &exitError{Stderr: stderr, Code: code}
+	}
+	return stdout, nil
+}
+
+// checkArgv returns an error if the recorded argv is of another command than argv
+func (r *replayCmd) checkArgv() error {
+	b, err := ioutil.ReadFile(filepath.Join(r.dir, recordArgv))
+	if err != nil {
+		return err
+	}
+	var recorded []string
+	if err := json.Unmarshal(b, &recorded); err != nil {
+		return fmt.Errorf("couldn't parse recorded argv in %s: %v", r.dir, err)
+	}
+	if strings.Join(comparableArgv(recorded), "\x00") != strings.Join(comparableArgv(r.argv), "\x00") {
+		return fmt.Errorf("the recording in %s is of `%s`, not of `%s`", r.dir, shellJoin(recorded), shellJoin(r.argv))
+	}
+	return nil
+}
+
+// comparableArgv returns argv without the json output mc adds for structured output and without the kubeconfig, which
+// is a temp file with --isolate-kubeconfig
+func comparableArgv(argv []string) (comparable []string) {
+	for i := 0; i < len(argv); i++ {
+		switch {
+		case (argv[i] == "-o" || argv[i] == "--output") && i+1 < len(argv) && argv[i+1] == "json":
+			i++
+		case argv[i] == "--kubeconfig" && i+1 < len(argv):
+			i++
+		case argv[i] == "-o=json" || argv[i] == "--output=json" || strings.HasPrefix(argv[i], "--kubeconfig="):
+		default:
+			comparable = append(comparable, argv[i])
+		}
+	}
+	return
+}
+
+// writeRecording writes the results of a single execution into dir
+func writeRecording(dir string, argv []string, stdout []byte, stderr []byte, code int) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	a, err := json.MarshalIndent(argv, "", "  ")
+	if err != nil {
+		return err
+	}
+	files := map[string][]byte{
+		recordArgv:     a,
+		recordStdout:   stdout,
+		recordStderr:   stderr,
+		recordExitCode: []byte(strconv.Itoa(code) + "\n"),
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), content, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listContextsRecordingDir returns the directory the output of the list contexts command is recorded to
+func listContextsRecordingDir(root string) string {
+	return filepath.Join(root, "config")
+}
+
+// configViewRecordingDir returns the directory the output of the config view command is recorded to
+func configViewRecordingDir(root string) string {
+	return filepath.Join(root, "config-view")
+}
+
+// recordingDir returns the directory a kubectl execution against the given context and namespace is recorded to.
+// Context names are escaped as they commonly contain characters like `/` or `:`
+func recordingDir(root string, context string, namespace string) string {
+	ns := emptyNamespace
+	if namespace != "" {
+		ns = url.PathEscape(namespace)
+	}
+	return filepath.Join(root, "contexts", url.PathEscape(context), ns)
+}
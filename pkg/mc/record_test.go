@@ -0,0 +1,135 @@
+package mc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jonnylangefeld/kubectl-mc/pkg/mc/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordCmd(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	m := mocks.NewMockCmd(ctrl)
+
+	tests := map[string]struct {
+		stdout       []byte
+		err          error
+		wantStderr   string
+		wantExitCode string
+	}{
+		"success": {
+			stdout:       kubectlReturn,
+			wantExitCode: "0\n",
+		},
+		"exit error": {
+			err:          &exec.ExitError{Stderr: []byte("Error: forbidden")},
+			wantStderr:   "Error: forbidden",
+			wantExitCode: "-1\n",
+		},
+		"other error": {
+			err:          assert.AnError,
+			wantStderr:   assert.AnError.Error(),
+			wantExitCode: "-1\n",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			argv := []string{"kubectl", "get", "pods", "--context", kubeContext}
+			m.EXPECT().Output().Return(test.stdout, test.err)
+
+			r := &recordCmd{cmd: m, dir: dir, argv: argv}
+			got, err := r.Output()
+			assert.Equal(t, test.err, err)
+			assert.Equal(t, test.stdout, got)
+
+			a, err := ioutil.ReadFile(filepath.Join(dir, recordArgv))
+			assert.NoError(t, err)
+			var gotArgv []string
+			assert.NoError(t, json.Unmarshal(a, &gotArgv))
+			assert.Equal(t, argv, gotArgv)
+			stderr, err := ioutil.ReadFile(filepath.Join(dir, recordStderr))
+			assert.NoError(t, err)
+			assert.Equal(t, test.wantStderr, string(stderr))
+			code, err := ioutil.ReadFile(filepath.Join(dir, recordExitCode))
+			assert.NoError(t, err)
+			assert.Equal(t, test.wantExitCode, string(code))
+		})
+	}
+}
+
+func TestReplayCmd(t *testing.T) {
+	dir := t.TempDir()
+
+	assert.NoError(t, writeRecording(filepath.Join(dir, "ok"), nil, kubectlReturn, nil, 0))
+	got, err := (&replayCmd{dir: filepath.Join(dir, "ok")}).Output()
+	assert.NoError(t, err)
+	assert.Equal(t, kubectlReturn, got)
+
+	assert.NoError(t, writeRecording(filepath.Join(dir, "failed"), nil, nil, []byte("Error: forbidden"), 1))
+	got, err = kubectl(&replayCmd{dir: filepath.Join(dir, "failed")})
+	assert.Nil(t, got)
+	assert.EqualError(t, err, "forbidden")
+
+	argv := []string{"kubectl", "get", "nodes", "-o", "json", "--context", kubeContext}
+	assert.NoError(t, writeRecording(filepath.Join(dir, "nodes"), argv, kubectlReturn, nil, 0))
+	got, err = (&replayCmd{dir: filepath.Join(dir, "nodes"), argv: []string{"kubectl", "get", "nodes", "--context", kubeContext}}).Output()
+	assert.NoError(t, err)
+	assert.Equal(t, kubectlReturn, got)
+	_, err = (&replayCmd{dir: filepath.Join(dir, "nodes"), argv: []string{"kubectl", "get", "pods", "--context", kubeContext}}).Output()
+	assert.EqualError(t, err, "the recording in "+filepath.Join(dir, "nodes")+" is of `kubectl get nodes -o json --context kind-kind`, not of `kubectl get pods --context kind-kind`")
+
+	_, err = (&replayCmd{dir: filepath.Join(dir, "missing")}).Output()
+	assert.EqualError(t, err, "no recording found in "+filepath.Join(dir, "missing"))
+}
+
+func TestRecordingDir(t *testing.T) {
+	assert.Equal(t, filepath.Join("rec", "contexts", "kind-kind", "default"), recordingDir("rec", kubeContext, namespace))
+	assert.Equal(t, filepath.Join("rec", "contexts", "arn:aws:eks:eu-west-1:1234:cluster%2Fprod", "_"), recordingDir("rec", "arn:aws:eks:eu-west-1:1234:cluster/prod", ""))
+}
+
+func TestMC_RecordAndReplay(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	m := mocks.NewMockCmd(ctrl)
+	dir := t.TempDir()
+
+	m.EXPECT().Output().Return([]byte("kind-kind\nkind-kind1\n"), nil)
+	m.EXPECT().Output().Return(kubectlReturnSA, nil).Times(2)
+
+	record := New("")
+	record.getListContextsCmd = func() Cmd {
+		return m
+	}
+	record.getKubectlCmd = func(ctx context.Context, args []string, c string, namespace string) Cmd {
+		return m
+	}
+	record.Cmd.SetOut(ioutil.Discard)
+	record.Cmd.SetArgs([]string{"-r", "kind", "--record", dir, "--", "get", "sa", "-o", "json"})
+	assert.NoError(t, record.Cmd.Execute())
+
+	// the replay doesn't have any mocked commands, so all output has to come from the recording
+	replay := New("")
+	b := bytes.NewBuffer([]byte(``))
+	replay.Cmd.SetOut(b)
+	durations := filepath.Join(t.TempDir(), durationsFile)
+	replay.Cmd.SetArgs([]string{"--replay", dir, "--durations-file", durations, "-o", "yaml", "--", "get", "sa"})
+	assert.NoError(t, replay.Cmd.Execute())
+	assert.Equal(t, yamlReturn, b.String())
+	_, err := os.Stat(durations)
+	assert.True(t, os.IsNotExist(err))
+
+	both := New("")
+	both.Cmd.SetOut(ioutil.Discard)
+	both.Cmd.SetErr(ioutil.Discard)
+	both.Cmd.SetArgs([]string{"--record", dir, "--replay", dir, "--", "get", "sa"})
+	assert.Equal(t, errRecordAndReplay, both.Cmd.Execute())
+}
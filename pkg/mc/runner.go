@@ -0,0 +1,343 @@
+package mc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const defaultMaxProc = 5
+
+// valueFlags are the kubectl flags that can be given before the verb and take a value as separate arg
+var valueFlags = map[string]bool{
+	"-n": true, "--namespace": true, "--context": true, "--cluster": true, "--user": true, "--kubeconfig": true,
+	"-s": true, "--server": true, "--token": true, "--as": true, "--as-group": true, "--as-uid": true,
+	"--certificate-authority": true, "--client-certificate": true, "--client-key": true, "--tls-server-name": true,
+	"--username": true, "--password": true, "--request-timeout": true, "--cache-dir": true, "-v": true, "--v": true,
+	"--vmodule": true, "--log-dir": true, "--log-file": true, "--log-file-max-size": true, "--log-flush-frequency": true,
+	"--log-backtrace-at": true, "--stderrthreshold": true, "--profile": true, "--profile-output": true,
+	"-l": true, "--selector": true, "-o": true, "--output": true,
+}
+
+// Options configure a Runner
+type Options struct {
+	// Regex filters the contexts of the kubeconfig. If empty all contexts are used
+	Regex string
+	// NegRegex excludes contexts from the ones matched by Regex
+	NegRegex string
+	// Namespaces to execute the kubectl args in. If empty the current namespace of every context is used
+	Namespaces []string
+	// MaxProc is the max amount of parallel kubectl processes. Defaults to 5
+	MaxProc int
+	// MaxProcPerContext is the max amount of parallel kubectl processes against a single context. If 0 only MaxProc
+	// limits them
+	MaxProcPerContext int
+	// OnResult is called with every result as soon as it is available. Calls are never concurrent
+	OnResult func(Result)
+
+	// ListContextsCmd returns the command listing all context names of the kubeconfig. Defaults to kubectl
+	ListContextsCmd func(ctx context.Context) Cmd
+	// KubectlCmd returns the command executing args against a context and namespace. Defaults to kubectl
+	KubectlCmd func(ctx context.Context, args []string, kubeContext string, namespace string) Cmd
+}
+
+// Runner executes kubectl commands against multiple contexts in parallel
+type Runner struct {
+	opts Options
+}
+
+// Result is the outcome of a single kubectl execution against a context and namespace
+type Result struct {
+	Context   string
+	Namespace string
+	Stdout    []byte
+	// Stderr is the raw stderr of a failed execution
+	Stderr []byte
+	// Err is the error of a failed execution, with the message cleaned up from the stderr
+	Err error
+	// ExitCode of the kubectl process. It is -1 if the process couldn't be started or its exit code is unknown
+	ExitCode int
+	Duration time.Duration
+	// Age of a result served from the cache. It is 0 for executed commands
+	Age time.Duration
+}
+
+// key returns the key of a result in structured output
+func (r Result) key() string {
+	if r.Namespace == "" {
+		return r.Context
+	}
+	return r.Context + ": " + r.Namespace
+}
+
+// NewRunner returns a Runner with the given options, using kubectl for every command that isn't set
+func NewRunner(opts Options) *Runner {
+	if opts.MaxProc < 1 {
+		opts.MaxProc = defaultMaxProc
+	}
+	if len(opts.Namespaces) == 0 {
+		opts.Namespaces = []string{""}
+	}
+	if opts.ListContextsCmd == nil {
+		opts.ListContextsCmd = func(ctx context.Context) Cmd {
+			return exec.CommandContext(ctx, "kubectl", listContextsArgs...)
+		}
+	}
+	if opts.KubectlCmd == nil {
+		opts.KubectlCmd = func(ctx context.Context, args []string, kubeContext string, namespace string) Cmd {
+			return exec.CommandContext(ctx, "kubectl", getLocalArgs(args, kubeContext, namespace)...)
+		}
+	}
+	return &Runner{opts: opts}
+}
+
+// ListContexts returns the names of all contexts matching the regex options
+func (r *Runner) ListContexts(ctx context.Context) ([]string, error) {
+	return r.listContexts(r.opts.ListContextsCmd(ctx))
+}
+
+// Run executes args against every namespace of every matching context and returns all results, sorted by context and
+// namespace. A failed kubectl execution doesn't fail the run, but is reported in the Err of its result
+func (r *Runner) Run(ctx context.Context, args []string) ([]Result, error) {
+	contexts, err := r.ListContexts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.RunContexts(ctx, contexts, args)
+}
+
+// RunContexts executes args against every namespace of the given contexts. The executions are interleaved across
+// contexts, so the namespaces of one context aren't executed back-to-back while other contexts wait. If ctx is
+// canceled no further kubectl processes are started and the results of the missing executions carry the context error
+func (r *Runner) RunContexts(ctx context.Context, contexts []string, args []string) ([]Result, error) {
+	logger.Debug("starting scheduler", zap.Int("max-processes", r.opts.MaxProc), zap.Int("max-processes-per-context", r.opts.MaxProcPerContext))
+	var mutex sync.Mutex
+	results := []Result{}
+	collect := func(res Result) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		results = append(results, res)
+		if r.opts.OnResult != nil {
+			r.opts.OnResult(res)
+		}
+	}
+
+	pending := schedule(contexts, r.opts.Namespaces)
+	running := map[string]int{}
+	done := make(chan string)
+	active := 0
+	for len(pending) > 0 || active > 0 {
+		if err := ctx.Err(); err != nil {
+			for _, j := range pending {
+				collect(Result{Context: j.context, Namespace: j.namespace, Err: err, ExitCode: -1})
+			}
+			pending = nil
+		}
+		for active < r.opts.MaxProc {
+			i := r.next(pending, running)
+			if i < 0 {
+				break
+			}
+			j := pending[i]
+			pending = append(pending[:i], pending[i+1:]...)
+			running[j.context]++
+			active++
+			logger.Debug("executing", zap.String("context", j.context), zap.String("namespace", j.namespace))
+			go func(j job) {
+				collect(do(j.context, j.namespace, r.opts.KubectlCmd(ctx, args, j.context, j.namespace)))
+				done <- j.context
+			}(j)
+		}
+		if active == 0 {
+			continue
+		}
+		// once everything pending is canceled, only the running executions are waited for
+		canceled := ctx.Done()
+		if len(pending) == 0 {
+			canceled = nil
+		}
+		logger.Debug("waiting for next free spot", zap.Int("pending", len(pending)), zap.Int("active", active))
+		select {
+		case c := <-done:
+			running[c]--
+			active--
+		case <-canceled:
+		}
+	}
+	logger.Debug("scheduler finished")
+
+	sortResults(results)
+	return results, ctx.Err()
+}
+
+// job is a single execution against a context and namespace
+type job struct {
+	context   string
+	namespace string
+}
+
+// schedule returns the jobs for all namespaces of all contexts, interleaved across contexts
+func schedule(contexts []string, namespaces []string) (jobs []job) {
+	for _, ns := range namespaces {
+		for _, c := range contexts {
+			jobs = append(jobs, job{context: c, namespace: ns})
+		}
+	}
+	return
+}
+
+// next returns the index of the first pending job whose context has a free spot, or -1 if there is none
+func (r *Runner) next(pending []job, running map[string]int) int {
+	for i, j := range pending {
+		if r.opts.MaxProcPerContext < 1 || running[j.context] < r.opts.MaxProcPerContext {
+			return i
+		}
+	}
+	return -1
+}
+
+// listContexts builds a list of context based on the regex options
+func (r *Runner) listContexts(cmd Cmd) (contexts []string, err error) {
+	re, err := regexp.Compile(r.opts.Regex)
+	if err != nil {
+		return nil, err
+	}
+	nr, err := regexp.Compile(r.opts.NegRegex)
+	if err != nil {
+		return nil, err
+	}
+
+	stdout, err := kubectl(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	s := bufio.NewScanner(bytes.NewReader(stdout))
+	for s.Scan() {
+		name := s.Bytes()
+		if re.Match(name) {
+			if r.opts.NegRegex != "" && nr.Match(name) {
+				continue
+			}
+			contexts = append(contexts, string(name))
+		}
+	}
+
+	return
+}
+
+// do executes a command against kubectl and returns its result
+func do(kubeContext string, namespace string, cmd Cmd) Result {
+	start := time.Now()
+	stdout, err := cmd.Output()
+	res := Result{Context: kubeContext, Namespace: namespace, Stdout: stdout, Duration: time.Since(start)}
+	if err != nil {
+		res.Stderr, res.Err, res.ExitCode = stderr(err), kubectlError(err), exitCode(err)
+		logger.Debug("kubectl error", zap.String("context", kubeContext), zap.String("namespace", namespace), zap.Error(res.Err))
+	}
+	if a, ok := cmd.(aged); ok {
+		res.Age = a.age()
+	}
+	return res
+}
+
+// kubectl executes a kubectl command
+func kubectl(cmd Cmd) ([]byte, error) {
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, kubectlError(err)
+	}
+	return out, nil
+}
+
+// stderr returns the stderr carried by the error of a failed command
+func stderr(err error) []byte {
+	switch err := err.(type) {
+	case *exec.ExitError:
+		return err.Stderr
+	case *exitError:
+		return err.Stderr
+	}
+	return nil
+}
+
+// exitCode returns the exit code carried by the error of a failed command, or -1 if it is unknown
+func exitCode(err error) int {
+	switch err := err.(type) {
+	case *exec.ExitError:
+		return err.ExitCode()
+	case *exitError:
+		return err.Code
+	}
+	return -1
+}
+
+// kubectlError turns the error of a failed command into an error with the cleaned up stderr as message
+func kubectlError(err error) error {
+	errString := err.Error()
+	if s := stderr(err); s != nil {
+		errString = string(s)
+	}
+	return fmt.Errorf(strings.Replace(strings.Replace(errString, "error: ", "", -1), "Error: ", "", -1))
+}
+
+// kubectlVerb returns the kubectl verb of args, which is the first arg that is neither a flag nor the value of one
+func kubectlVerb(args []string) string {
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--" {
+			break
+		}
+		if !strings.HasPrefix(args[i], "-") {
+			return args[i]
+		}
+		if valueFlags[args[i]] {
+			i++
+		}
+	}
+	return ""
+}
+
+// getLocalArgs transforms kubectl args slice by injecting the context flag into the right position.
+// if the kubectl command contained `--` (for instance for a `kubectl exec` command, we inject the context flag before
+// that.
+func getLocalArgs(args []string, context string, namespace string) (localArgs []string) {
+
+	var skipContext bool
+	for _, arg := range args {
+		if arg == "--" {
+			// If this is given, we need to insert the context before this arg
+			localArgs = append(localArgs, "--context", context)
+			if len(namespace) > 0 {
+				localArgs = append(localArgs, "--namespace", namespace)
+			}
+			skipContext = true
+		}
+		localArgs = append(localArgs, arg)
+	}
+	if !skipContext {
+		localArgs = append(localArgs, "--context", context)
+		if len(namespace) > 0 {
+			localArgs = append(localArgs, "--namespace", namespace)
+		}
+	}
+	return
+}
+
+// sortResults sorts results by context and namespace
+func sortResults(results []Result) {
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Context != results[j].Context {
+			return results[i].Context < results[j].Context
+		}
+		return results[i].Namespace < results[j].Namespace
+	})
+}
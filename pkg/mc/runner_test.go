@@ -0,0 +1,144 @@
+package mc
+
+import (
+	"context"
+	"os/exec"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jonnylangefeld/kubectl-mc/pkg/mc/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunner_Run(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	list := mocks.NewMockCmd(ctrl)
+	succeeded := mocks.NewMockCmd(ctrl)
+	failed := mocks.NewMockCmd(ctrl)
+
+	list.EXPECT().Output().Return([]byte("kind-kind\nkind-kind1\nfoo\n"), nil)
+	succeeded.EXPECT().Output().Return(kubectlReturn, nil).Times(2)
+	failed.EXPECT().Output().Return(nil, &exec.ExitError{Stderr: []byte("Error: forbidden")}).Times(2)
+
+	var mutex sync.Mutex
+	callbacks := map[string]bool{}
+	r := NewRunner(Options{
+		Regex:      "kind",
+		Namespaces: []string{"default", "kube-system"},
+		MaxProc:    2,
+		OnResult: func(res Result) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			callbacks[res.key()] = true
+		},
+		ListContextsCmd: func(ctx context.Context) Cmd {
+			return list
+		},
+		KubectlCmd: func(ctx context.Context, args []string, kubeContext string, namespace string) Cmd {
+			assert.Equal(t, []string{"get", "pods"}, args)
+			if kubeContext == "kind-kind" {
+				return succeeded
+			}
+			return failed
+		},
+	})
+
+	got, err := r.Run(context.Background(), []string{"get", "pods"})
+	assert.NoError(t, err)
+	assert.Len(t, got, 4)
+	assert.Len(t, callbacks, 4)
+	for i := range got {
+		got[i].Duration = 0
+	}
+	assert.Equal(t, Result{Context: "kind-kind", Namespace: "default", Stdout: kubectlReturn}, got[0])
+	assert.Equal(t, Result{Context: "kind-kind", Namespace: "kube-system", Stdout: kubectlReturn}, got[1])
+	assert.Equal(t, "kind-kind1", got[2].Context)
+	assert.Equal(t, []byte("Error: forbidden"), got[2].Stderr)
+	assert.Equal(t, -1, got[2].ExitCode)
+	assert.EqualError(t, got[2].Err, "forbidden")
+}
+
+func TestRunner_RunContextsCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	r := NewRunner(Options{
+		KubectlCmd: func(ctx context.Context, args []string, kubeContext string, namespace string) Cmd {
+			t.Fatal("no command must be executed after the context is canceled")
+			return nil
+		},
+	})
+
+	got, err := r.RunContexts(ctx, []string{"kind-kind", "kind-kind1"}, []string{"get", "pods"})
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, []Result{{Context: "kind-kind", Err: context.Canceled, ExitCode: -1}, {Context: "kind-kind1", Err: context.Canceled, ExitCode: -1}}, got)
+}
+
+func TestRunner_RunContextsPerContextLimit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	var mutex sync.Mutex
+	running := map[string]int{}
+	maxRunning := map[string]int{}
+	var order []string
+
+	r := NewRunner(Options{
+		Namespaces:        []string{"a", "b", "c"},
+		MaxProc:           4,
+		MaxProcPerContext: 1,
+		KubectlCmd: func(ctx context.Context, args []string, kubeContext string, namespace string) Cmd {
+			m := mocks.NewMockCmd(ctrl)
+			m.EXPECT().Output().DoAndReturn(func() ([]byte, error) {
+				mutex.Lock()
+				running[kubeContext]++
+				if running[kubeContext] > maxRunning[kubeContext] {
+					maxRunning[kubeContext] = running[kubeContext]
+				}
+				order = append(order, kubeContext+"/"+namespace)
+				mutex.Unlock()
+				time.Sleep(time.Millisecond)
+				mutex.Lock()
+				running[kubeContext]--
+				mutex.Unlock()
+				return nil, nil
+			})
+			return m
+		},
+	})
+
+	got, err := r.RunContexts(context.Background(), []string{"kind-kind", "kind-kind1"}, []string{"get", "pods"})
+	assert.NoError(t, err)
+	assert.Len(t, got, 6)
+	assert.Equal(t, map[string]int{"kind-kind": 1, "kind-kind1": 1}, maxRunning)
+	// both contexts are started before any of them executes its second namespace
+	assert.ElementsMatch(t, []string{"kind-kind/a", "kind-kind1/a"}, order[:2])
+}
+
+func TestKubectlVerb(t *testing.T) {
+	tests := map[string]struct {
+		args []string
+		want string
+	}{
+		"verb first":        {args: []string{"get", "pods"}, want: "get"},
+		"bool flag":         {args: []string{"-A", "get", "pods"}, want: "get"},
+		"flag with value":   {args: []string{"-n", "x", "--context", "y", "get", "pods"}, want: "get"},
+		"flag with = value": {args: []string{"--namespace=x", "-nx", "describe", "pods"}, want: "describe"},
+		"only flags":        {args: []string{"-n", "x"}},
+		"after double dash": {args: []string{"-v", "5", "--", "get"}},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.want, kubectlVerb(test.args))
+		})
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	assert.Equal(t, []job{
+		{context: "kind-kind", namespace: "a"},
+		{context: "kind-kind1", namespace: "a"},
+		{context: "kind-kind", namespace: "b"},
+		{context: "kind-kind1", namespace: "b"},
+	}, schedule([]string{"kind-kind", "kind-kind1"}, []string{"a", "b"}))
+}
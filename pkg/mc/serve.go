@@ -0,0 +1,376 @@
+package mc
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+const (
+	serveTokenEnv     = "MC_SERVE_TOKEN"
+	defaultServeAddr  = "127.0.0.1:8080"
+	contentTypeJSON   = "application/json"
+	contentTypeNDJSON = "application/x-ndjson"
+	bearerPrefix      = "Bearer "
+	serveReadTimeout  = 30 * time.Second
+)
+
+var (
+	// serveFlags are the only flags allowed in the args of a request. They select, filter and format what the
+	// read-only verbs return, but neither change the cluster, the credentials or the namespaces of the request nor
+	// read or write local files. The value is whether the flag takes a value
+	serveFlags = map[string]bool{
+		"-o": true, "--output": true, "-l": true, "--selector": true, "--field-selector": true, "-L": true,
+		"--label-columns": true, "--sort-by": true, "--chunk-size": true, "--subresource": true, "--show-labels": false,
+		"--show-kind": false, "--show-managed-fields": false, "--no-headers": false, "--ignore-not-found": false,
+		"--show-events": false, "--containers": false, "--use-protocol-buffers": false, "-c": true, "--container": true,
+		"--tail": true, "--since": true, "--since-time": true, "--limit-bytes": true, "--timestamps": false, "-p": false,
+		"--previous": false, "--all-containers": false, "--prefix": false, "--for": true, "--types": true,
+		"--recursive": false, "--api-version": true, "--namespaced": false, "--verbs": true, "--api-group": true,
+		"--client": false,
+	}
+	// serveOutputs are the output formats allowed in the args of a request. Formats ending with = take an inline
+	// template or expression, formats reading a file are not allowed
+	serveOutputs = []string{"json", "yaml", "wide", "name", "jsonpath=", "jsonpath-as-json=", "custom-columns=", "go-template=", "template="}
+
+	errServeVerbFirst = fmt.Errorf("the first arg has to be the kubectl verb")
+	errServeStreaming = fmt.Errorf("commands that run until they are interrupted, like --watch or --follow, are not allowed")
+	errServeToken     = fmt.Errorf("a token is required. Set it with --token or the %s environment variable", serveTokenEnv)
+)
+
+// server is the HTTP API of mc serve
+type server struct {
+	mc      *MC
+	token   string
+	allowed map[string]bool
+	// auditMutex serializes the audit log entries of concurrent requests
+	auditMutex sync.Mutex
+}
+
+// serveRequest selects the contexts and namespaces to run args against
+type serveRequest struct {
+	Regex      string   `json:"regex,omitempty"`
+	NegRegex   string   `json:"negativeRegex,omitempty"`
+	Group      string   `json:"group,omitempty"`
+	Namespaces []string `json:"namespaces,omitempty"`
+	Args       []string `json:"args"`
+}
+
+// serveResult is the envelope of a single result. Output is set if the stdout is json, Stdout otherwise. CacheAge is
+// set for results served from the cache
+type serveResult struct {
+	Context   string          `json:"context"`
+	Namespace string          `json:"namespace,omitempty"`
+	Output    json.RawMessage `json:"output,omitempty"`
+	Stdout    string          `json:"stdout,omitempty"`
+	Error     string          `json:"error,omitempty"`
+	ExitCode  int             `json:"exitCode"`
+	Duration  string          `json:"duration"`
+	CacheAge  string          `json:"cacheAge,omitempty"`
+}
+
+// newServeCmd returns the serve command, which exposes context selection and fan-out as HTTP API
+func (mc *MC) newServeCmd() *cobra.Command {
+	var addr, token string
+	var verbs []string
+	writeTimeout := 5 * time.Minute
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve an HTTP/JSON API to list contexts and run read-only kubectl commands against them",
+		Example: `
+# serve the API on localhost
+MC_SERVE_TOKEN=secret mc serve
+
+# list the contexts of the group eu and get the pods of all prod clusters, streaming every result as soon as it is available
+curl -H 'Authorization: Bearer secret' 'localhost:8080/v1/contexts?group=eu'
+curl -H 'Authorization: Bearer secret' -H 'Accept: application/x-ndjson' localhost:8080/v1/run -d '{"regex": "prod", "args": ["get", "pods", "-o", "json"]}'`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logger, _ = zap.NewProduction()
+			defer logger.Sync()
+			if token == "" {
+				token = os.Getenv(serveTokenEnv)
+			}
+			if token == "" {
+				return errServeToken
+			}
+			var err error
+			if mc.config, err = loadConfig(mc.ConfigPath); err != nil {
+				return err
+			}
+			if mc.Replay != "" {
+				mc.useReplay()
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "listening on %s\n", addr)
+			srv := &http.Server{
+				Addr:         addr,
+				Handler:      newServer(mc, token, verbs),
+				ReadTimeout:  serveReadTimeout,
+				WriteTimeout: writeTimeout,
+			}
+			return srv.ListenAndServe()
+		},
+	}
+	cmd.Flags().StringVar(&addr, "listen", defaultServeAddr, "the address to listen on")
+	cmd.Flags().StringVar(&token, "token", token, fmt.Sprintf("the bearer token clients have to send. Defaults to the %s environment variable", serveTokenEnv))
+	cmd.Flags().StringSliceVar(&verbs, "allow-verbs", readOnlyVerbs, "the kubectl verbs clients are allowed to run")
+	cmd.Flags().DurationVar(&writeTimeout, "write-timeout", writeTimeout, "the max duration of a request including its response. Responses of longer requests are cut off")
+	cmd.Flags().IntVarP(&mc.MaxProc, "max-processes", "p", 5, "max amount of parallel kubectl per request")
+	cmd.Flags().StringVar(&mc.ConfigPath, "config", mc.ConfigPath, "path to the mc config file")
+	cmd.Flags().StringVar(&mc.Replay, "replay", mc.Replay, "serve a run previously saved with --record from this directory instead of calling kubectl")
+	return cmd
+}
+
+// newServer returns the handler of the API
+func newServer(mc *MC, token string, verbs []string) http.Handler {
+	s := &server{mc: mc, token: token, allowed: map[string]bool{}}
+	for _, v := range verbs {
+		s.allowed[v] = true
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/contexts", s.authenticated(s.contexts))
+	mux.HandleFunc("/v1/run", s.authenticated(s.run))
+	return mux
+}
+
+// authenticated only calls next for requests with the bearer token of the server
+func (s *server) authenticated(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, bearerPrefix) || subtle.ConstantTimeCompare([]byte(auth[len(bearerPrefix):]), []byte(s.token)) != 1 {
+			writeError(w, http.StatusUnauthorized, fmt.Errorf("invalid or missing bearer token"))
+			return
+		}
+		next(w, r)
+	}
+}
+
+// contexts lists the contexts matching the regex, negativeRegex or group query parameters
+func (s *server) contexts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	q := r.URL.Query()
+	req := serveRequest{Regex: q.Get("regex"), NegRegex: q.Get("negativeRegex"), Group: q.Get("group")}
+	contexts, err := s.runner(r.Context(), req, nil).ListContexts(r.Context())
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	if contexts == nil {
+		contexts = []string{}
+	}
+	w.Header().Set("Content-Type", contentTypeJSON)
+	json.NewEncoder(w).Encode(map[string][]string{"contexts": contexts})
+}
+
+// run executes the args of the request against all selected contexts. If the client accepts JSONL, every result is
+// streamed as soon as it is available, otherwise all results are returned at once
+func (s *server) run(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	var req serveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request: %v", err))
+		return
+	}
+	if err := s.validate(req.Args); err != nil {
+		writeError(w, http.StatusForbidden, err)
+		return
+	}
+
+	if strings.Contains(r.Header.Get("Accept"), contentTypeNDJSON) {
+		w.Header().Set("Content-Type", contentTypeNDJSON)
+		enc := json.NewEncoder(w)
+		flusher, _ := w.(http.Flusher)
+		onResult := func(res Result) {
+			enc.Encode(envelope(res))
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		results, err := s.runner(r.Context(), req, onResult).Run(r.Context(), req.Args)
+		if err == nil {
+			err = s.audit(req, results)
+		}
+		if err != nil {
+			enc.Encode(map[string]string{"error": err.Error()})
+		}
+		return
+	}
+
+	results, err := s.runner(r.Context(), req, nil).Run(r.Context(), req.Args)
+	if err == nil {
+		err = s.audit(req, results)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	envelopes := []serveResult{}
+	for _, res := range results {
+		envelopes = append(envelopes, envelope(res))
+	}
+	w.Header().Set("Content-Type", contentTypeJSON)
+	json.NewEncoder(w).Encode(map[string][]serveResult{"results": envelopes})
+}
+
+// validate returns an error if args use a verb that isn't allowed, a flag or output format that isn't allowed or a
+// flag that makes kubectl run until it is interrupted.
+// The verb has to be the first arg, as global flags before it can take a value that would be mistaken for the verb
+func (s *server) validate(args []string) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return errServeVerbFirst
+	}
+	if verb := args[0]; !s.allowed[verb] {
+		return fmt.Errorf("the verb %q is not allowed", verb)
+	}
+	if streaming(args) {
+		return errServeStreaming
+	}
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			return nil
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			continue
+		}
+		name, value, hasValue := arg, "", false
+		if j := strings.Index(arg, "="); strings.HasPrefix(arg, "--") && j >= 0 {
+			name, value, hasValue = arg[:j], arg[j+1:], true
+		} else if !strings.HasPrefix(arg, "--") && len(arg) > 2 {
+			name, value, hasValue = arg[:2], strings.TrimPrefix(arg[2:], "="), true
+		}
+		takesValue, ok := serveFlags[name]
+		if !ok {
+			return fmt.Errorf("the flag %s is not allowed", name)
+		}
+		// combined shorthands like -pf are parsed as several flags
+		if !takesValue && hasValue && arg[len(name)] != '=' {
+			return fmt.Errorf("the flag %s is not allowed", arg)
+		}
+		if takesValue && !hasValue && i+1 < len(args) {
+			i++
+			value = args[i]
+		}
+		if (name == "-o" || name == "--output") && !serveOutput(value) {
+			return fmt.Errorf("the output %q is not allowed", value)
+		}
+	}
+	return nil
+}
+
+// serveOutput returns true if output is one of the serveOutputs
+func serveOutput(output string) bool {
+	for _, o := range serveOutputs {
+		if output == o || (strings.HasSuffix(o, "=") && strings.HasPrefix(output, o)) {
+			return true
+		}
+	}
+	return false
+}
+
+// audit appends an entry for the request to the audit log. Its argv runs the same request with mc
+func (s *server) audit(req serveRequest, results []Result) error {
+	if s.mc.AuditLog == "" {
+		return nil
+	}
+	regex := req.Regex
+	if req.Group != "" {
+		regex = req.Group
+	}
+	var argv []string
+	if regex != "" {
+		argv = append(argv, "--regex="+regex)
+	}
+	if req.NegRegex != "" {
+		argv = append(argv, "--negative-regex="+req.NegRegex)
+	}
+	if len(req.Namespaces) > 0 {
+		argv = append(argv, "--namespaces="+strings.Join(req.Namespaces, ","))
+	}
+	argv = append(append(argv, "--"), req.Args...)
+
+	var contexts []string
+	seen := map[string]bool{}
+	for _, r := range results {
+		if !seen[r.Context] {
+			contexts = append(contexts, r.Context)
+			seen[r.Context] = true
+		}
+	}
+
+	s.auditMutex.Lock()
+	defer s.auditMutex.Unlock()
+	if err := writeAudit(s.mc.AuditLog, argv, contexts, req.Namespaces, results); err != nil {
+		return fmt.Errorf("couldn't write audit log: %v", err)
+	}
+	return nil
+}
+
+// runner returns a Runner for the selection of a request. Concurrent requests share the commands of mc, which are
+// safe to use concurrently
+func (s *server) runner(ctx context.Context, req serveRequest, onResult func(Result)) *Runner {
+	regex := req.Regex
+	if req.Group != "" {
+		regex = req.Group
+	}
+	var mutex sync.Mutex
+	opts := Options{
+		Regex:      s.mc.config.expandGroup(regex),
+		NegRegex:   s.mc.config.expandGroup(req.NegRegex),
+		Namespaces: req.Namespaces,
+		MaxProc:    s.mc.MaxProc,
+		ListContextsCmd: func(ctx context.Context) Cmd {
+			return s.mc.listContextsCmd()
+		},
+		KubectlCmd: func(ctx context.Context, args []string, kubeContext string, namespace string) Cmd {
+			return s.mc.kubectlCmd(ctx, args, kubeContext, namespace)
+		},
+	}
+	if onResult != nil {
+		opts.OnResult = func(res Result) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			onResult(res)
+		}
+	}
+	return NewRunner(opts)
+}
+
+// envelope returns the API representation of a result
+func envelope(r Result) serveResult {
+	e := serveResult{Context: r.Context, Namespace: r.Namespace, ExitCode: r.ExitCode, Duration: r.Duration.String()}
+	if json.Valid(r.Stdout) {
+		e.Output = r.Stdout
+	} else {
+		e.Stdout = string(r.Stdout)
+	}
+	if r.Err != nil {
+		e.Error = r.Err.Error()
+	}
+	if r.Age > 0 {
+		e.CacheAge = r.Age.Round(time.Second).String()
+	}
+	return e
+}
+
+// writeError writes err as json error response
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", contentTypeJSON)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
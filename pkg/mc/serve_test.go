@@ -0,0 +1,262 @@
+package mc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jonnylangefeld/kubectl-mc/pkg/mc/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	m := mocks.NewMockCmd(ctrl)
+	m.EXPECT().Output().Return([]byte("kind-kind\nkind-kind1\nfoo\n"), nil).AnyTimes()
+	kubectl := mocks.NewMockCmd(ctrl)
+	kubectl.EXPECT().Output().Return([]byte(`{"items":[]}`), nil).AnyTimes()
+
+	mc := New("")
+	mc.config = &config{Groups: map[string]string{"kinds": "kind-kind\\d"}}
+	mc.MaxProc = 5
+	mc.AuditLog = filepath.Join(t.TempDir(), auditLogFile)
+	mc.getListContextsCmd = func() Cmd {
+		return m
+	}
+	var mutex sync.Mutex
+	var calls [][]string
+	mc.getKubectlCmd = func(ctx context.Context, args []string, c string, namespace string) Cmd {
+		mutex.Lock()
+		defer mutex.Unlock()
+		calls = append(calls, append(args, c, namespace))
+		return kubectl
+	}
+	s := httptest.NewServer(newServer(mc, "secret", readOnlyVerbs))
+	defer s.Close()
+
+	tests := map[string]struct {
+		method   string
+		path     string
+		token    string
+		auth     string
+		accept   string
+		body     string
+		wantCode int
+		want     string
+	}{
+		"missing token": {
+			method:   http.MethodGet,
+			path:     "/v1/contexts",
+			wantCode: http.StatusUnauthorized,
+			want:     `{"error":"invalid or missing bearer token"}`,
+		},
+		"token without bearer prefix": {
+			method:   http.MethodGet,
+			path:     "/v1/contexts",
+			auth:     "secret",
+			wantCode: http.StatusUnauthorized,
+			want:     `{"error":"invalid or missing bearer token"}`,
+		},
+		"list contexts": {
+			method:   http.MethodGet,
+			path:     "/v1/contexts?regex=kind",
+			token:    "secret",
+			wantCode: http.StatusOK,
+			want:     `{"contexts":["kind-kind","kind-kind1"]}`,
+		},
+		"list contexts of a group": {
+			method:   http.MethodGet,
+			path:     "/v1/contexts?group=kinds",
+			token:    "secret",
+			wantCode: http.StatusOK,
+			want:     `{"contexts":["kind-kind1"]}`,
+		},
+		"wrong method": {
+			method:   http.MethodGet,
+			path:     "/v1/run",
+			token:    "secret",
+			wantCode: http.StatusMethodNotAllowed,
+			want:     `{"error":"method GET not allowed"}`,
+		},
+		"verb not allowed": {
+			method:   http.MethodPost,
+			path:     "/v1/run",
+			token:    "secret",
+			body:     `{"regex":"foo","args":["delete","pods","--all"]}`,
+			wantCode: http.StatusForbidden,
+			want:     `{"error":"the verb \"delete\" is not allowed"}`,
+		},
+		"flag before the verb": {
+			method:   http.MethodPost,
+			path:     "/v1/run",
+			token:    "secret",
+			body:     `{"regex":"foo","args":["--cache-dir","get","delete","pods","--all"]}`,
+			wantCode: http.StatusForbidden,
+			want:     `{"error":"the first arg has to be the kubectl verb"}`,
+		},
+		"no args": {
+			method:   http.MethodPost,
+			path:     "/v1/run",
+			token:    "secret",
+			body:     `{"regex":"foo","args":[]}`,
+			wantCode: http.StatusForbidden,
+			want:     `{"error":"the first arg has to be the kubectl verb"}`,
+		},
+		"streaming flag": {
+			method:   http.MethodPost,
+			path:     "/v1/run",
+			token:    "secret",
+			body:     `{"regex":"foo","args":["logs","deploy/x","-f"]}`,
+			wantCode: http.StatusForbidden,
+			want:     `{"error":"commands that run until they are interrupted, like --watch or --follow, are not allowed"}`,
+		},
+		"flag not allowed": {
+			method:   http.MethodPost,
+			path:     "/v1/run",
+			token:    "secret",
+			body:     `{"regex":"foo","args":["get","pods","--kubeconfig=/tmp/config"]}`,
+			wantCode: http.StatusForbidden,
+			want:     `{"error":"the flag --kubeconfig is not allowed"}`,
+		},
+		"invalid body": {
+			method:   http.MethodPost,
+			path:     "/v1/run",
+			token:    "secret",
+			body:     `{`,
+			wantCode: http.StatusBadRequest,
+			want:     `{"error":"invalid request: unexpected EOF"}`,
+		},
+		"run": {
+			method:   http.MethodPost,
+			path:     "/v1/run",
+			token:    "secret",
+			body:     `{"regex":"foo","namespaces":["default"],"args":["get","pods","-o","json"]}`,
+			wantCode: http.StatusOK,
+			want:     `{"results":[{"context":"foo","namespace":"default","output":{"items":[]},"exitCode":0,"duration":"0s"}]}`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			req, err := http.NewRequest(test.method, s.URL+test.path, strings.NewReader(test.body))
+			assert.NoError(t, err)
+			if test.token != "" {
+				req.Header.Set("Authorization", "Bearer "+test.token)
+			}
+			if test.auth != "" {
+				req.Header.Set("Authorization", test.auth)
+			}
+			res, err := http.DefaultClient.Do(req)
+			assert.NoError(t, err)
+			defer res.Body.Close()
+			var got map[string]interface{}
+			assert.NoError(t, json.NewDecoder(res.Body).Decode(&got))
+			// durations vary between runs
+			if results, ok := got["results"].([]interface{}); ok {
+				for _, r := range results {
+					r.(map[string]interface{})["duration"] = "0s"
+				}
+			}
+			b, _ := json.Marshal(got)
+			assert.Equal(t, test.wantCode, res.StatusCode)
+			assert.JSONEq(t, test.want, string(b))
+		})
+	}
+	assert.Equal(t, [][]string{{"get", "pods", "-o", "json", "foo", "default"}}, calls)
+
+	entries, err := readAuditLog(mc.AuditLog)
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, []string{"--regex=foo", "--namespaces=default", "--", "get", "pods", "-o", "json"}, entries[0].Argv)
+		assert.Equal(t, []string{"foo"}, entries[0].Contexts)
+		assert.Equal(t, []string{"default"}, entries[0].Namespaces)
+		assert.Equal(t, []auditResult{{Context: "foo", Namespace: "default", Status: statusSucceeded, Duration: entries[0].Results[0].Duration}}, entries[0].Results)
+	}
+}
+
+func TestServer_Validate(t *testing.T) {
+	s := &server{allowed: map[string]bool{"get": true, "logs": true}}
+	tests := map[string]struct {
+		args    []string
+		wantErr string
+	}{
+		"allowed flags":        {args: []string{"get", "pods", "-l", "app=x", "--show-labels", "-o", "wide", "--sort-by=.metadata.name"}},
+		"allowed shorthands":   {args: []string{"logs", "deploy/x", "-cmain", "-p", "--tail=10"}},
+		"inline template":      {args: []string{"get", "pods", "-o", "jsonpath={.items[*].metadata.name}"}},
+		"after double dash":    {args: []string{"get", "--", "--pods"}},
+		"profile":              {args: []string{"get", "pods", "--profile=cpu", "--profile-output=/home/u/.bashrc"}, wantErr: "the flag --profile is not allowed"},
+		"log file":             {args: []string{"get", "pods", "--log-file=/tmp/x"}, wantErr: "the flag --log-file is not allowed"},
+		"filename":             {args: []string{"get", "--filename=https://evil/x.yaml"}, wantErr: "the flag --filename is not allowed"},
+		"template file":        {args: []string{"get", "pods", "-o", "go-template-file=/etc/shadow"}, wantErr: `the output "go-template-file=/etc/shadow" is not allowed`},
+		"attached output":      {args: []string{"get", "pods", "-ojsonpath-file=/etc/shadow"}, wantErr: `the output "jsonpath-file=/etc/shadow" is not allowed`},
+		"insecure":             {args: []string{"get", "pods", "--insecure-skip-tls-verify"}, wantErr: "the flag --insecure-skip-tls-verify is not allowed"},
+		"namespace":            {args: []string{"get", "pods", "-nkube-system"}, wantErr: "the flag -n is not allowed"},
+		"combined shorthands":  {args: []string{"logs", "deploy/x", "-pA"}, wantErr: "the flag -pA is not allowed"},
+		"output without value": {args: []string{"get", "pods", "-o"}, wantErr: `the output "" is not allowed`},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := s.validate(test.args)
+			if test.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestServer_Stream(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	m := mocks.NewMockCmd(ctrl)
+	m.EXPECT().Output().Return([]byte("kind-kind\nkind-kind1\n"), nil)
+	kubectl := mocks.NewMockCmd(ctrl)
+	kubectl.EXPECT().Output().Return([]byte("NAME\nfoo\n"), nil).Times(2)
+
+	mc := New("")
+	mc.config = &config{}
+	mc.MaxProc = 1
+	mc.getListContextsCmd = func() Cmd {
+		return m
+	}
+	mc.getKubectlCmd = func(ctx context.Context, args []string, c string, namespace string) Cmd {
+		return kubectl
+	}
+	s := httptest.NewServer(newServer(mc, "secret", readOnlyVerbs))
+	defer s.Close()
+
+	req, err := http.NewRequest(http.MethodPost, s.URL+"/v1/run", bytes.NewBufferString(`{"regex":"kind","args":["get","pods"]}`))
+	assert.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("Accept", contentTypeNDJSON)
+	res, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, contentTypeNDJSON, res.Header.Get("Content-Type"))
+
+	var contexts []string
+	dec := json.NewDecoder(res.Body)
+	for dec.More() {
+		var r serveResult
+		assert.NoError(t, dec.Decode(&r))
+		assert.Equal(t, "NAME\nfoo\n", r.Stdout)
+		contexts = append(contexts, r.Context)
+	}
+	assert.ElementsMatch(t, []string{"kind-kind", "kind-kind1"}, contexts)
+}
+
+func TestServeTokenRequired(t *testing.T) {
+	t.Setenv(serveTokenEnv, "")
+	mc := New("")
+	mc.Cmd.SetArgs([]string{"serve"})
+	mc.Cmd.SetOut(&bytes.Buffer{})
+	mc.Cmd.SetErr(&bytes.Buffer{})
+	assert.Equal(t, errServeToken, mc.Cmd.Execute())
+}
@@ -0,0 +1,233 @@
+package mc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// CSV represents the string for csv
+	CSV = "csv"
+	// TSV represents the string for tsv
+	TSV = "tsv"
+
+	namespaceColumn = "NAMESPACE"
+	// cacheAgeColumn holds the age of cached results. It is only added if the cache is enabled
+	cacheAgeColumn = "CACHE AGE"
+)
+
+var (
+	// columnSeparator matches the padding between two columns of a kubectl table, which is at least two spaces. That
+	// still allows for single spaces in headers like `NOMINATED NODE`
+	columnSeparator = regexp.MustCompile(`\s{2,}`)
+
+	errInvalidColumns = fmt.Errorf("invalid columns. Use the format NAME:JSONPATH,... like NAME:.metadata.name,NODE:.spec.nodeName")
+)
+
+// column is a custom column for csv and tsv output, evaluated via jsonpath
+type column struct {
+	name string
+	path string
+}
+
+// table holds the flattened rows of one or more results with a header that is the union of all their columns
+type table struct {
+	header []string
+	rows   []map[string]string
+}
+
+// parseColumns parses custom columns in the format NAME:JSONPATH,NAME:JSONPATH. The jsonpaths are validated upfront,
+// so that a typo fails before any kubectl command runs
+func parseColumns(s string) (columns []column, err error) {
+	if s == "" {
+		return nil, nil
+	}
+	for _, c := range strings.Split(s, ",") {
+		parts := strings.SplitN(c, ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, errInvalidColumns
+		}
+		if _, err := jsonPath(nil, parts[1]); err != nil {
+			return nil, err
+		}
+		columns = append(columns, column{name: parts[0], path: parts[1]})
+	}
+	return
+}
+
+// isTabular returns true for the output options that flatten results into rows
+func isTabular(output string) bool {
+	return output == CSV || output == TSV
+}
+
+// writeTable writes all successful results as csv or tsv rows. Every row starts with the context, the namespace and,
+// if the cache is enabled, the cache age, followed by the custom columns or, if none are given, the columns of the
+// kubectl table output
+func (mc *MC) writeTable(out io.Writer, results []Result) error {
+	columns, err := parseColumns(mc.Columns)
+	if err != nil {
+		return err
+	}
+
+	sortResults(results)
+
+	t := &table{}
+	for _, r := range results {
+		if r.Err != nil {
+			continue
+		}
+		if err := t.add(r, columns); err != nil {
+			logger.Debug("failed to parse output", zap.String("context", r.Context), zap.ByteString("retrieved", r.Stdout))
+			return errCouldntParseOutput
+		}
+	}
+
+	w := csv.NewWriter(out)
+	if mc.Output == TSV {
+		w.Comma = '\t'
+	}
+	header := []string{"CONTEXT", namespaceColumn}
+	if mc.CacheTTL > 0 {
+		header = append(header, cacheAgeColumn)
+	}
+	header = append(header, t.header...)
+	if err := w.Write(header); err != nil {
+		return err
+	}
+	for _, row := range t.rows {
+		var record []string
+		for _, h := range header {
+			record = append(record, row[h])
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// add flattens a single result into rows. If columns are given the stdout is expected to be json and every item
+// of a list becomes a row. Otherwise the stdout is parsed as kubectl table
+func (t *table) add(r Result, columns []column) error {
+	var header []string
+	var rows []map[string]string
+	var err error
+	if len(columns) > 0 {
+		header, rows, err = jsonRows(r.Stdout, columns)
+	} else {
+		header, rows, err = tableRows(r.Stdout)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, h := range header {
+		if h == namespaceColumn || contains(t.header, h) {
+			continue
+		}
+		t.header = append(t.header, h)
+	}
+	for _, row := range rows {
+		row["CONTEXT"] = r.Context
+		if row[namespaceColumn] == "" {
+			row[namespaceColumn] = r.Namespace
+		}
+		if r.Age > 0 {
+			row[cacheAgeColumn] = r.Age.Round(time.Second).String()
+		}
+		t.rows = append(t.rows, row)
+	}
+	return nil
+}
+
+// jsonRows evaluates the columns against every item of a json list, or against the object itself if it isn't a list.
+// The namespace of every row is taken from the metadata of the item
+func jsonRows(stdout []byte, columns []column) (header []string, rows []map[string]string, err error) {
+	var obj map[string]interface{}
+	if err := json.Unmarshal(stdout, &obj); err != nil {
+		return nil, nil, err
+	}
+	items := []interface{}{obj}
+	if i, ok := obj["items"].([]interface{}); ok {
+		items = i
+	}
+
+	for _, c := range columns {
+		header = append(header, c.name)
+	}
+	for _, item := range items {
+		row := map[string]string{}
+		if row[namespaceColumn], err = jsonPathString(item, ".metadata.namespace"); err != nil {
+			return nil, nil, err
+		}
+		for _, c := range columns {
+			if row[c.name], err = jsonPathString(item, c.path); err != nil {
+				return nil, nil, err
+			}
+		}
+		rows = append(rows, row)
+	}
+	return
+}
+
+// tableRows parses the table output of kubectl. The columns are sliced by the position of the headers, as values
+// can contain spaces as well
+func tableRows(stdout []byte) (header []string, rows []map[string]string, err error) {
+	s := bufio.NewScanner(bytes.NewReader(stdout))
+	var starts []int
+	for s.Scan() {
+		line := []rune(s.Text())
+		if strings.TrimSpace(string(line)) == "" {
+			continue
+		}
+		if header == nil {
+			h := string(line)
+			starts = append(starts, 0)
+			for _, sep := range columnSeparator.FindAllStringIndex(h, -1) {
+				starts = append(starts, len([]rune(h[:sep[1]])))
+			}
+			for i := range starts {
+				header = append(header, cell(line, starts, i))
+			}
+			continue
+		}
+		row := map[string]string{}
+		for i, h := range header {
+			row[h] = cell(line, starts, i)
+		}
+		rows = append(rows, row)
+	}
+	return header, rows, s.Err()
+}
+
+// cell returns the trimmed value of the i-th column of a table line
+func cell(line []rune, starts []int, i int) string {
+	if starts[i] >= len(line) {
+		return ""
+	}
+	end := len(line)
+	if i+1 < len(starts) && starts[i+1] < end {
+		end = starts[i+1]
+	}
+	return strings.TrimSpace(string(line[starts[i]:end]))
+}
+
+// contains returns true if s is an element of list
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
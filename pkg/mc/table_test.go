@@ -0,0 +1,106 @@
+package mc
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jonnylangefeld/kubectl-mc/pkg/mc/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMC_WriteTable(t *testing.T) {
+	tests := map[string]struct {
+		args           []string
+		kubectlReturns [][]byte
+		want           string
+		wantErr        string
+	}{
+		"csv from table output": {
+			args: []string{"-r", "kind", "-o", "csv", "--", "get", "pods", "-A"},
+			kubectlReturns: [][]byte{
+				[]byte("NAMESPACE     NAME                      READY   STATUS    NOMINATED NODE\nkube-system   coredns-66bff467f8-4lnsg  1/1     Running   <none>\n"),
+			},
+			want: "CONTEXT,NAMESPACE,NAME,READY,STATUS,NOMINATED NODE\nkind-kind,kube-system,coredns-66bff467f8-4lnsg,1/1,Running,<none>\n",
+		},
+		"csv with custom columns": {
+			args:           []string{"-r", "kind", "-o", "csv", "--columns", "NAME:.metadata.name,SECRETS:.secrets[*].name", "--", "get", "sa"},
+			kubectlReturns: [][]byte{kubectlReturnSA},
+			want:           "CONTEXT,NAMESPACE,NAME,SECRETS\nkind-kind,default,default,default-token-6x8kn\n",
+		},
+		"tsv with quoting": {
+			args:           []string{"-r", "kind", "-o", "tsv", "--columns", "NAME:.metadata.name,SELF:.metadata.selfLink,ITEMS:.secrets", "--", "get", "sa"},
+			kubectlReturns: [][]byte{kubectlReturnSA},
+			want:           "CONTEXT\tNAMESPACE\tNAME\tSELF\tITEMS\nkind-kind\tdefault\tdefault\t/api/v1/namespaces/default/serviceaccounts/default\t\"[{\"\"name\"\":\"\"default-token-6x8kn\"\"}]\"\n",
+		},
+		"invalid columns": {
+			args:    []string{"-r", "kind", "-o", "csv", "--columns", "NAME", "--", "get", "sa"},
+			wantErr: errInvalidColumns.Error(),
+		},
+		"invalid jsonpath": {
+			args:    []string{"-r", "kind", "-o", "csv", "--columns", "NAME:.metadata.name,SECRET:.secrets[first].name", "--", "get", "sa"},
+			wantErr: `invalid index in jsonpath ".secrets[first].name": strconv.Atoi: parsing "first": invalid syntax`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			m := mocks.NewMockCmd(ctrl)
+			if test.wantErr == "" {
+				m.EXPECT().Output().Return([]byte("kind-kind\n"), nil)
+			}
+			for _, r := range test.kubectlReturns {
+				m.EXPECT().Output().Return(r, nil)
+			}
+			if test.wantErr == "" {
+				m.EXPECT().Output().Return(kubectlReturnSA, nil).AnyTimes()
+			}
+			mc := New("")
+			mc.getListContextsCmd = func() Cmd {
+				return m
+			}
+			mc.getKubectlCmd = func(ctx context.Context, args []string, c string, namespace string) Cmd {
+				return m
+			}
+			b := bytes.NewBuffer([]byte(``))
+			mc.Cmd.SetOut(b)
+			mc.Cmd.SetErr(bytes.NewBuffer([]byte(``)))
+			mc.Cmd.SetArgs(test.args)
+			err := mc.Cmd.Execute()
+			// invalid columns fail before any context is listed or kubectl runs
+			if test.wantErr != "" {
+				assert.EqualError(t, err, test.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.want, b.String())
+		})
+	}
+}
+
+func TestTableRows(t *testing.T) {
+	header, rows, err := tableRows(kubectlReturn)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"NAME", "READY", "STATUS", "RESTARTS", "AGE"}, header)
+	assert.Len(t, rows, 8)
+	assert.Equal(t, map[string]string{"NAME": "kube-scheduler-kind-control-plane", "READY": "1/1", "STATUS": "Running", "RESTARTS": "0", "AGE": "14h"}, rows[7])
+
+	header, rows, err = tableRows(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, header)
+	assert.Nil(t, rows)
+}
+
+func TestParseColumns(t *testing.T) {
+	got, err := parseColumns("NAME:.metadata.name,NODE:{.spec.nodeName}")
+	assert.NoError(t, err)
+	assert.Equal(t, []column{{name: "NAME", path: ".metadata.name"}, {name: "NODE", path: "{.spec.nodeName}"}}, got)
+
+	_, err = parseColumns("NAME:")
+	assert.Equal(t, errInvalidColumns, err)
+
+	_, err = parseColumns("NAME:.metadata.name,NODE:.spec[nodeName")
+	assert.EqualError(t, err, `unterminated index in jsonpath ".spec[nodeName"`)
+}
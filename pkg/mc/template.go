@@ -0,0 +1,80 @@
+package mc
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// safeShellArg matches args that don't need to be quoted in a shell
+var safeShellArg = regexp.MustCompile(`^[a-zA-Z0-9_@%+=:,./-]+$`)
+
+// errorCmd is a Cmd that fails with err without executing anything
+type errorCmd struct {
+	err error
+}
+
+// Output returns the error of the command
+func (e *errorCmd) Output() ([]byte, error) {
+	return nil, e.err
+}
+
+// templateVars returns the variables available in templated kubectl args of a context and namespace. These are the
+// custom vars of the context from the config file, plus Context, Namespace, Cluster, User and Server from the
+// kubeconfig. If no namespace is given the default namespace of the context is used
+func templateVars(c *config, k *kubeconfig, kubeContext string, namespace string) map[string]string {
+	vars := map[string]string{}
+	for key, value := range c.Contexts[kubeContext].Vars {
+		vars[key] = value
+	}
+	info := k.context(kubeContext)
+	if namespace == "" {
+		namespace = info.Namespace
+	}
+	if namespace == "" {
+		namespace = "default"
+	}
+	vars["Context"] = kubeContext
+	vars["Namespace"] = namespace
+	vars["Cluster"] = info.Cluster
+	vars["User"] = info.User
+	vars["Server"] = info.Server
+	return vars
+}
+
+// renderArgs renders every arg as go template with the given variables. Referencing a variable that doesn't exist
+// is an error, so that no command is executed with half rendered args
+func renderArgs(args []string, vars map[string]string) ([]string, error) {
+	rendered := make([]string, 0, len(args))
+	for _, arg := range args {
+		if !strings.Contains(arg, "{{") {
+			rendered = append(rendered, arg)
+			continue
+		}
+		tmpl, err := template.New("arg").Option("missingkey=error").Parse(arg)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't parse template %q: %v", arg, err)
+		}
+		b := bytes.NewBuffer([]byte(``))
+		if err := tmpl.Execute(b, vars); err != nil {
+			return nil, fmt.Errorf("couldn't render template %q: %v", arg, err)
+		}
+		rendered = append(rendered, b.String())
+	}
+	return rendered, nil
+}
+
+// shellJoin joins argv into a command line that can be pasted into a POSIX shell, quoting args where necessary
+func shellJoin(argv []string) string {
+	quoted := make([]string, 0, len(argv))
+	for _, arg := range argv {
+		if arg != "" && safeShellArg.MatchString(arg) {
+			quoted = append(quoted, arg)
+			continue
+		}
+		quoted = append(quoted, "'"+strings.Replace(arg, "'", `'\''`, -1)+"'")
+	}
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,97 @@
+package mc
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jonnylangefeld/kubectl-mc/pkg/mc/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+var kubeconfigView = []byte(`{
+  "contexts": [
+    {"name": "kind-kind", "context": {"cluster": "kind-kind", "user": "kind-kind", "namespace": "apps"}},
+    {"name": "kind-kind1", "context": {"cluster": "kind-kind1", "user": "kind-kind1"}}
+  ],
+  "clusters": [
+    {"name": "kind-kind", "cluster": {"server": "https://127.0.0.1:6443"}},
+    {"name": "kind-kind1", "cluster": {"server": "https://127.0.0.1:6444"}}
+  ]
+}`)
+
+func TestMC_Template(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	m := mocks.NewMockCmd(ctrl)
+	configPath := filepath.Join(t.TempDir(), configFile)
+	assert.NoError(t, ioutil.WriteFile(configPath, []byte(`contexts:
+  kind-kind:
+    vars:
+      Region: eu
+  kind-kind1:
+    vars:
+      Region: us
+`), 0644))
+
+	m.EXPECT().Output().Return(kubeconfigView, nil)
+	m.EXPECT().Output().Return([]byte("kind-kind\nkind-kind1\n"), nil)
+
+	mc := New("")
+	mc.getListContextsCmd = func() Cmd {
+		return m
+	}
+	mc.getConfigViewCmd = func() Cmd {
+		return m
+	}
+	// keep the order of the kubeconfig
+	mc.DurationsFile = ""
+	b := bytes.NewBuffer([]byte(``))
+	mc.Cmd.SetOut(b)
+	mc.Cmd.SetArgs([]string{"-r", "kind", "--config", configPath, "--template", "--print-args", "--", "set", "image", "deploy/x", "app=registry.{{.Region}}/app:1.2", "-l", "server={{.Server}}"})
+	assert.NoError(t, mc.Cmd.Execute())
+	assert.Equal(t, `kubectl set image deploy/x app=registry.eu/app:1.2 -l server=https://127.0.0.1:6443 --context kind-kind
+kubectl set image deploy/x app=registry.us/app:1.2 -l server=https://127.0.0.1:6444 --context kind-kind1
+`, b.String())
+}
+
+func TestTemplateVars(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	m := mocks.NewMockCmd(ctrl)
+	m.EXPECT().Output().Return(kubeconfigView, nil)
+
+	k, err := loadKubeconfig(m)
+	assert.NoError(t, err)
+	c := &config{Contexts: map[string]contextConfig{kubeContext: {Vars: map[string]string{"Region": "eu", "Context": "overridden"}}}}
+
+	assert.Equal(t, map[string]string{
+		"Region":    "eu",
+		"Context":   "kind-kind",
+		"Namespace": "apps",
+		"Cluster":   "kind-kind",
+		"User":      "kind-kind",
+		"Server":    "https://127.0.0.1:6443",
+	}, templateVars(c, k, kubeContext, ""))
+	assert.Equal(t, "kube-system", templateVars(c, k, kubeContext, "kube-system")["Namespace"])
+	assert.Equal(t, "default", templateVars(c, k, "kind-kind1", "")["Namespace"])
+}
+
+func TestRenderArgs(t *testing.T) {
+	vars := map[string]string{"Context": kubeContext, "Region": "eu"}
+
+	got, err := renderArgs([]string{"apply", "-f", "overlays/{{.Context}}/", "-l", "region={{.Region}}"}, vars)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"apply", "-f", "overlays/kind-kind/", "-l", "region=eu"}, got)
+
+	_, err = renderArgs([]string{"{{.Missing}}"}, vars)
+	assert.Error(t, err)
+
+	_, err = renderArgs([]string{"{{.Context"}, vars)
+	assert.Error(t, err)
+}
+
+func TestShellJoin(t *testing.T) {
+	assert.Equal(t, `kubectl get pods -l app=x --context kind-kind`, shellJoin([]string{"kubectl", "get", "pods", "-l", "app=x", "--context", kubeContext}))
+	assert.Equal(t, `kubectl exec x -- sh -c 'echo '\''hi'\'' > /tmp/x' ''`, shellJoin([]string{"kubectl", "exec", "x", "--", "sh", "-c", "echo 'hi' > /tmp/x", ""}))
+}
@@ -0,0 +1,266 @@
+package mc
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	tracesPath       = "/v1/traces"
+	traceScope       = "kubectl-mc"
+	traceTimeout     = 10 * time.Second
+	spanKindClient   = 3
+	spanKindInternal = 1
+	statusCodeError  = 2
+)
+
+// tracer collects the spans of an invocation: a root span and a child span per kubectl execution. They are exported in
+// the OTLP/JSON encoding, either as a line of a file or to the HTTP endpoint of a collector
+type tracer struct {
+	mutex   sync.Mutex
+	traceID string
+	root    span
+	spans   []span
+	// queued is when the executions were handed to the scheduler, which is the start of their queue wait
+	queued time.Time
+}
+
+// span is a single operation of a trace
+type span struct {
+	id         string
+	parentID   string
+	name       string
+	kind       int
+	start      time.Time
+	end        time.Time
+	attributes map[string]interface{}
+	events     []spanEvent
+	err        error
+}
+
+// spanEvent is a point in time within a span
+type spanEvent struct {
+	name string
+	time time.Time
+}
+
+// newTracer returns a tracer with a started root span for an invocation with args
+func newTracer(args []string) *tracer {
+	now := time.Now()
+	return &tracer{
+		traceID: randomID(16),
+		root: span{
+			id:         randomID(8),
+			name:       strings.TrimSpace("mc " + kubectlVerb(args)),
+			kind:       spanKindInternal,
+			start:      now,
+			attributes: map[string]interface{}{"mc.args": strings.Join(args, " ")},
+		},
+		queued: now,
+	}
+}
+
+// queue marks the start of the queue wait of all following executions
+func (t *tracer) queue() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.queued = time.Now()
+}
+
+// finish ends the root span with the error of the invocation
+func (t *tracer) finish(err error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.root.end = time.Now()
+	t.root.err = err
+	t.root.attributes["mc.executions"] = len(t.spans)
+}
+
+// tracedCmd wraps a Cmd and adds a span for its execution against a context and namespace to the tracer
+type tracedCmd struct {
+	cmd       Cmd
+	tracer    *tracer
+	args      []string
+	context   string
+	namespace string
+}
+
+// Output executes the wrapped command. The span starts with the queue wait of the command and ends when it exited
+func (c *tracedCmd) Output() ([]byte, error) {
+	c.tracer.mutex.Lock()
+	queued := c.tracer.queued
+	c.tracer.mutex.Unlock()
+
+	started := time.Now()
+	stdout, err := c.cmd.Output()
+	end := time.Now()
+
+	s := span{
+		id:       randomID(8),
+		parentID: c.tracer.root.id,
+		name:     strings.TrimSpace("kubectl " + kubectlVerb(c.args)),
+		kind:     spanKindClient,
+		start:    queued,
+		end:      end,
+		attributes: map[string]interface{}{
+			"mc.context":        c.context,
+			"mc.namespace":      c.namespace,
+			"mc.queue_wait_ms":  started.Sub(queued).Milliseconds(),
+			"mc.run_ms":         end.Sub(started).Milliseconds(),
+			"process.exit_code": 0,
+		},
+		events: []spanEvent{{name: "kubectl started", time: started}},
+	}
+	if err != nil {
+		s.err = kubectlError(err)
+		s.attributes["process.exit_code"] = exitCode(err)
+	}
+	if age := c.age(); age > 0 {
+		s.attributes["mc.cache_age_ms"] = age.Milliseconds()
+	}
+
+	c.tracer.mutex.Lock()
+	defer c.tracer.mutex.Unlock()
+	c.tracer.spans = append(c.tracer.spans, s)
+	return stdout, err
+}
+
+// age returns the age of the wrapped command, if it was served from the cache
+func (c *tracedCmd) age() time.Duration {
+	if a, ok := c.cmd.(aged); ok {
+		return a.age()
+	}
+	return 0
+}
+
+// traced returns cmd wrapped to be traced, if tracing is enabled
+func (mc *MC) traced(cmd Cmd, args []string, context string, namespace string) Cmd {
+	if mc.tracer == nil {
+		return cmd
+	}
+	return &tracedCmd{cmd: cmd, tracer: mc.tracer, args: args, context: context, namespace: namespace}
+}
+
+// exportTrace writes the trace to the trace file and sends it to the trace endpoint, whichever is set
+func (mc *MC) exportTrace() error {
+	b, err := json.Marshal(mc.tracer.request())
+	if err != nil {
+		return err
+	}
+	if mc.TraceFile != "" {
+		f, err := os.OpenFile(mc.TraceFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(append(b, '\n')); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+	if mc.TraceEndpoint != "" {
+		url := strings.TrimSuffix(mc.TraceEndpoint, "/")
+		if !strings.HasSuffix(url, tracesPath) {
+			url += tracesPath
+		}
+		res, err := (&http.Client{Timeout: traceTimeout}).Post(url, contentTypeJSON, bytes.NewReader(b))
+		if err != nil {
+			return err
+		}
+		res.Body.Close()
+		if res.StatusCode/100 != 2 {
+			return fmt.Errorf("%s responded with %s", url, res.Status)
+		}
+	}
+	return nil
+}
+
+// request returns the OTLP/JSON export request of all spans
+func (t *tracer) request() map[string]interface{} {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	spans := []interface{}{t.root.otlp(t.traceID)}
+	for _, s := range t.spans {
+		spans = append(spans, s.otlp(t.traceID))
+	}
+	return map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{
+				"attributes": otlpAttributes(map[string]interface{}{"service.name": traceScope}),
+			},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]interface{}{"name": traceScope},
+				"spans": spans,
+			}},
+		}},
+	}
+}
+
+// otlp returns the OTLP/JSON representation of the span
+func (s span) otlp(traceID string) map[string]interface{} {
+	o := map[string]interface{}{
+		"traceId":           traceID,
+		"spanId":            s.id,
+		"name":              s.name,
+		"kind":              s.kind,
+		"startTimeUnixNano": strconv.FormatInt(s.start.UnixNano(), 10),
+		"endTimeUnixNano":   strconv.FormatInt(s.end.UnixNano(), 10),
+		"attributes":        otlpAttributes(s.attributes),
+	}
+	if s.parentID != "" {
+		o["parentSpanId"] = s.parentID
+	}
+	var events []interface{}
+	for _, e := range s.events {
+		events = append(events, map[string]interface{}{"name": e.name, "timeUnixNano": strconv.FormatInt(e.time.UnixNano(), 10)})
+	}
+	if events != nil {
+		o["events"] = events
+	}
+	if s.err != nil {
+		o["status"] = map[string]interface{}{"code": statusCodeError, "message": s.err.Error()}
+	}
+	return o
+}
+
+// otlpAttributes returns attributes as OTLP key values, sorted by key
+func otlpAttributes(attributes map[string]interface{}) []interface{} {
+	keys := make([]string, 0, len(attributes))
+	for k := range attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	kvs := []interface{}{}
+	for _, k := range keys {
+		var value map[string]interface{}
+		switch v := attributes[k].(type) {
+		case int:
+			value = map[string]interface{}{"intValue": strconv.Itoa(v)}
+		case int64:
+			value = map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+		default:
+			value = map[string]interface{}{"stringValue": fmt.Sprint(v)}
+		}
+		kvs = append(kvs, map[string]interface{}{"key": k, "value": value})
+	}
+	return kvs
+}
+
+// randomID returns a random hex id of n bytes, as used for trace and span ids
+func randomID(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,134 @@
+package mc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jonnylangefeld/kubectl-mc/pkg/mc/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+// otlpRequest is the part of an OTLP/JSON export request the tests look at
+type otlpRequest struct {
+	ResourceSpans []struct {
+		ScopeSpans []struct {
+			Spans []struct {
+				TraceID      string `json:"traceId"`
+				SpanID       string `json:"spanId"`
+				ParentSpanID string `json:"parentSpanId"`
+				Name         string `json:"name"`
+				Attributes   []struct {
+					Key   string            `json:"key"`
+					Value map[string]string `json:"value"`
+				} `json:"attributes"`
+				Status *struct {
+					Code    int    `json:"code"`
+					Message string `json:"message"`
+				} `json:"status"`
+			} `json:"spans"`
+		} `json:"scopeSpans"`
+	} `json:"resourceSpans"`
+}
+
+func TestMC_Trace(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	list := mocks.NewMockCmd(ctrl)
+	succeeded := mocks.NewMockCmd(ctrl)
+	failed := mocks.NewMockCmd(ctrl)
+	list.EXPECT().Output().Return([]byte("kind-kind\nkind-kind1\n"), nil).Times(2)
+	succeeded.EXPECT().Output().Return(kubectlReturn, nil).Times(2)
+	failed.EXPECT().Output().Return(nil, &exitError{Stderr: []byte("Error: forbidden"), Code: 1}).Times(2)
+
+	var received []byte
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		received, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer collector.Close()
+	traceFile := filepath.Join(t.TempDir(), "trace.jsonl")
+
+	execute := func(args ...string) {
+		mc := New("")
+		mc.getListContextsCmd = func() Cmd {
+			return list
+		}
+		mc.getKubectlCmd = func(ctx context.Context, args []string, c string, namespace string) Cmd {
+			if c == kubeContext {
+				return succeeded
+			}
+			return failed
+		}
+		mc.Cmd.SetOut(bytes.NewBuffer([]byte(``)))
+		mc.Cmd.SetArgs(append(args, "--", "get", "pods"))
+		assert.NoError(t, mc.Cmd.Execute())
+	}
+	execute("--trace-file", traceFile)
+	execute("--trace-endpoint", collector.URL)
+
+	b, err := ioutil.ReadFile(traceFile)
+	assert.NoError(t, err)
+	for _, trace := range [][]byte{b, received} {
+		var req otlpRequest
+		assert.NoError(t, json.Unmarshal(trace, &req))
+		spans := req.ResourceSpans[0].ScopeSpans[0].Spans
+		assert.Len(t, spans, 3)
+		root := spans[0]
+		assert.Equal(t, "mc get", root.Name)
+		assert.Len(t, root.TraceID, 32)
+		assert.Empty(t, root.ParentSpanID)
+		assert.Nil(t, root.Status)
+
+		attributes := map[string]map[string]string{}
+		for _, s := range spans[1:] {
+			assert.Equal(t, "kubectl get", s.Name)
+			assert.Equal(t, root.TraceID, s.TraceID)
+			assert.Equal(t, root.SpanID, s.ParentSpanID)
+			a := map[string]string{}
+			for _, kv := range s.Attributes {
+				for _, v := range kv.Value {
+					a[kv.Key] = v
+				}
+			}
+			if s.Status != nil {
+				a["status"] = s.Status.Message
+			}
+			attributes[a["mc.context"]] = a
+		}
+		assert.Equal(t, "0", attributes[kubeContext]["process.exit_code"])
+		assert.Contains(t, attributes[kubeContext], "mc.queue_wait_ms")
+		assert.Contains(t, attributes[kubeContext], "mc.run_ms")
+		assert.Equal(t, "1", attributes["kind-kind1"]["process.exit_code"])
+		assert.Equal(t, "forbidden", attributes["kind-kind1"]["status"])
+	}
+}
+
+func TestMC_TraceEndpointError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	list := mocks.NewMockCmd(ctrl)
+	list.EXPECT().Output().Return([]byte("kind-kind\n"), nil)
+	m := mocks.NewMockCmd(ctrl)
+	m.EXPECT().Output().Return(kubectlReturn, nil)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer collector.Close()
+
+	mc := New("")
+	mc.getListContextsCmd = func() Cmd {
+		return list
+	}
+	mc.getKubectlCmd = func(ctx context.Context, args []string, c string, namespace string) Cmd {
+		return m
+	}
+	mc.Cmd.SetOut(bytes.NewBuffer([]byte(``)))
+	mc.Cmd.SetErr(ioutil.Discard)
+	mc.Cmd.SetArgs([]string{"--trace-endpoint", collector.URL + "/v1/traces", "--", "get", "pods"})
+	assert.EqualError(t, mc.Cmd.Execute(), "couldn't export trace: "+collector.URL+"/v1/traces responded with 503 Service Unavailable")
+}
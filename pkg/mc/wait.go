@@ -0,0 +1,309 @@
+package mc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+const (
+	waitForDelete = "delete"
+
+	defaultWaitTimeout  = 5 * time.Minute
+	defaultWaitInterval = 2 * time.Second
+)
+
+var errInvalidWaitFor = fmt.Errorf("invalid --for. Use condition=NAME, condition=NAME=VALUE or delete")
+
+// waitCondition is the condition all objects have to meet
+type waitCondition struct {
+	delete    bool
+	condition string
+	value     string
+}
+
+// waitState is the state of the objects of a single context and namespace
+type waitState struct {
+	// cells are the condition states by object
+	cells map[string]string
+	// blocking are the objects that don't meet the condition yet
+	blocking []string
+	// err is set if the objects couldn't be retrieved
+	err string
+}
+
+// waiter holds the options of the wait command
+type waiter struct {
+	For      string
+	Timeout  time.Duration
+	Interval time.Duration
+}
+
+// newWaitCmd returns the wait command, which waits for a condition of objects across all contexts
+func (mc *MC) newWaitCmd() *cobra.Command {
+	w := &waiter{}
+	cmd := &cobra.Command{
+		Use:   "wait --for=condition=NAME[=VALUE]|delete -- TYPE[/NAME] [kubectl get flags]",
+		Short: "Wait for a condition of objects in all contexts, showing a live matrix of contexts and objects",
+		Example: `
+# wait until the deployment app is available in all prod clusters
+mc wait -r prod --for condition=Available -- deploy/app
+
+# wait until all pods with the label app=x are deleted in all kind clusters, for at most one minute
+mc wait -r kind --for delete --timeout 1m -- pods -l app=x`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cond, err := parseWaitFor(w.For)
+			if err != nil {
+				return err
+			}
+			if mc.config, err = loadConfig(mc.ConfigPath); err != nil {
+				return err
+			}
+			mc.Regex, mc.NegRegex = mc.config.expandGroup(mc.Regex), mc.config.expandGroup(mc.NegRegex)
+			mc.argv = append([]string{"wait"}, argv(cmd.Flags(), args)...)
+			return mc.wait(cmd.Context(), cond, args, w.Timeout, w.Interval)
+		},
+	}
+	cmd.Flags().StringVarP(&mc.Regex, "regex", "r", mc.Regex, "a regex to filter the list of context names in kubeconfig, or the name of a group in the config file. If not given all contexts are used")
+	cmd.Flags().StringVarP(&mc.NegRegex, "negative-regex", "x", mc.NegRegex, "a regex to exclude matches from the result set, or the name of a group in the config file")
+	cmd.Flags().StringVarP(&mc.Namespaces, "namespaces", "n", mc.Namespaces, "comma-separated list of namespaces. The default is the current namespace of the context")
+	cmd.Flags().IntVarP(&mc.MaxProc, "max-processes", "p", 5, "max amount of parallel kubectl to be executed")
+	cmd.Flags().StringVar(&w.For, "for", w.For, "the condition to wait for. One of condition=NAME, condition=NAME=VALUE or delete. The default value of a condition is true")
+	cmd.Flags().DurationVar(&w.Timeout, "timeout", defaultWaitTimeout, "give up waiting after this duration")
+	cmd.Flags().DurationVar(&w.Interval, "interval", defaultWaitInterval, "interval in which the objects of contexts that don't meet the condition yet are checked again")
+	cmd.MarkFlagRequired("for")
+	cmd.RegisterFlagCompletionFunc("regex", mc.completeRegex)
+	cmd.RegisterFlagCompletionFunc("negative-regex", mc.completeRegex)
+	return cmd
+}
+
+// parseWaitFor parses the --for flag of the wait command
+func parseWaitFor(s string) (waitCondition, error) {
+	if s == waitForDelete {
+		return waitCondition{delete: true}, nil
+	}
+	parts := strings.SplitN(s, "=", 3)
+	if len(parts) < 2 || parts[0] != "condition" || parts[1] == "" {
+		return waitCondition{}, errInvalidWaitFor
+	}
+	c := waitCondition{condition: parts[1], value: "true"}
+	if len(parts) == 3 {
+		c.value = parts[2]
+	}
+	return c, nil
+}
+
+// wait polls the objects selected by args in all contexts until they meet the condition or the timeout hits, which
+// also kills kubectl processes that are still running. Every poll redraws the matrix of contexts and objects. Contexts
+// whose objects meet the condition aren't polled anymore.
+// The last result of every context and namespace is audited once the wait is over
+func (mc *MC) wait(ctx context.Context, cond waitCondition, args []string, timeout time.Duration, interval time.Duration) (err error) {
+	// finished is when the last execution against every context and namespace returned
+	finished := map[string]time.Time{}
+	r := mc.runner(func(res Result) {
+		finished[res.key()] = time.Now()
+	})
+	contexts, err := r.ListContexts(ctx)
+	if err != nil {
+		return err
+	}
+	last := map[string]Result{}
+	if mc.AuditLog != "" {
+		defer func() {
+			results := make([]Result, 0, len(last))
+			for _, res := range last {
+				results = append(results, res)
+			}
+			sortResults(results)
+			if aerr := mc.audit(mc.argv, contexts, results); aerr != nil && err == nil {
+				err = fmt.Errorf("couldn't write audit log: %v", aerr)
+			}
+		}()
+	}
+
+	start := time.Now()
+	ctx, cancel := context.WithDeadline(ctx, start.Add(timeout))
+	defer cancel()
+	getArgs := append(append([]string{"get"}, args...), "-o", "json")
+	states := map[string]waitState{}
+	var keys []string
+	pending := contexts
+	clearTerminal := isTerminal(mc.Cmd.OutOrStdout())
+	for {
+		results, err := r.RunContexts(ctx, pending, getArgs)
+		timedOut := errors.Is(err, context.DeadlineExceeded)
+		if err != nil && !timedOut {
+			return err
+		}
+		for _, res := range results {
+			last[res.key()] = res
+			state, ok := states[res.key()]
+			if !ok {
+				keys = append(keys, res.key())
+			}
+			// executions killed by the timeout keep the state of their previous poll
+			if timedOut && res.Err != nil && !finished[res.key()].Before(start.Add(timeout)) {
+				if !ok {
+					states[res.key()] = waitState{err: "no response within the timeout"}
+				} else {
+					states[res.key()] = state
+				}
+				continue
+			}
+			states[res.key()] = cond.evaluate(res)
+		}
+		sort.Strings(keys)
+
+		pending = nil
+		for _, res := range results {
+			if !states[res.key()].done() && !contains(pending, res.Context) {
+				pending = append(pending, res.Context)
+			}
+		}
+		if err := writeWaitMatrix(mc.Cmd.OutOrStdout(), keys, states, clearTerminal); err != nil {
+			return err
+		}
+		logger.Debug("waiting", zap.Strings("pending", pending))
+
+		elapsed := time.Since(start).Round(time.Second)
+		if len(pending) == 0 {
+			fmt.Fprintf(mc.Cmd.OutOrStdout(), "\nall objects in %d contexts met the condition after %s\n", len(contexts), elapsed)
+			return nil
+		}
+		remaining := timeout - time.Since(start)
+		if timedOut || remaining <= 0 {
+			return waitTimeoutError(timeout, keys, states)
+		}
+		if remaining > interval {
+			remaining = interval
+		}
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return waitTimeoutError(timeout, keys, states)
+			}
+			return ctx.Err()
+		case <-time.After(remaining):
+		}
+	}
+}
+
+// evaluate returns the state of the objects of a single result
+func (c waitCondition) evaluate(r Result) waitState {
+	s := waitState{cells: map[string]string{}}
+	if r.Err != nil {
+		if strings.Contains(r.Err.Error(), "NotFound") {
+			if !c.delete {
+				s.err = "not found"
+			}
+			return s
+		}
+		s.err = firstLine(r.Err.Error())
+		return s
+	}
+
+	var obj map[string]interface{}
+	if err := json.Unmarshal(r.Stdout, &obj); err != nil {
+		s.err = errCouldntParseOutput.Error()
+		return s
+	}
+	items := []interface{}{obj}
+	if i, ok := obj["items"].([]interface{}); ok {
+		items = i
+	}
+	if len(items) == 0 && !c.delete {
+		s.err = "no matching resources found"
+	}
+	for _, item := range items {
+		kind, _ := jsonPathString(item, ".kind")
+		name, _ := jsonPathString(item, ".metadata.name")
+		object := kind + "/" + name
+		if c.delete {
+			s.cells[object] = "exists"
+			s.blocking = append(s.blocking, object)
+			continue
+		}
+		status := "<none>"
+		conditions, _ := jsonPath(item, ".status.conditions[*]")
+		for _, cond := range conditions {
+			if t, _ := jsonPathString(cond, ".type"); strings.EqualFold(t, c.condition) {
+				status, _ = jsonPathString(cond, ".status")
+			}
+		}
+		s.cells[object] = status
+		if !strings.EqualFold(status, c.value) {
+			s.blocking = append(s.blocking, object)
+		}
+	}
+	return s
+}
+
+// done returns true if all objects meet the condition
+func (s waitState) done() bool {
+	return s.err == "" && len(s.blocking) == 0
+}
+
+// writeWaitMatrix writes a row for every context and namespace, with a column for every object that was found in any
+// of them. If clearTerminal is set the terminal is cleared first, otherwise every matrix is appended to the output
+func writeWaitMatrix(out io.Writer, keys []string, states map[string]waitState, clearTerminal bool) error {
+	var objects []string
+	for _, k := range keys {
+		for o := range states[k].cells {
+			if !contains(objects, o) {
+				objects = append(objects, o)
+			}
+		}
+	}
+	sort.Strings(objects)
+
+	if clearTerminal {
+		fmt.Fprint(out, clearScreen)
+	}
+	w := tabwriter.NewWriter(out, 0, 8, 3, ' ', 0)
+	fmt.Fprintf(w, "CONTEXT\t%s\tSTATUS\n", strings.Join(objects, "\t"))
+	for _, k := range keys {
+		s := states[k]
+		row := []string{k}
+		for _, o := range objects {
+			cell, ok := s.cells[o]
+			if !ok {
+				cell = "-"
+			}
+			row = append(row, cell)
+		}
+		status := "done"
+		switch {
+		case s.err != "":
+			status = "error: " + s.err
+		case len(s.blocking) > 0:
+			status = "waiting"
+		}
+		fmt.Fprintln(w, strings.Join(append(row, status), "\t"))
+	}
+	return w.Flush()
+}
+
+// waitTimeoutError returns the error reporting which contexts blocked and why
+func waitTimeoutError(timeout time.Duration, keys []string, states map[string]waitState) error {
+	var blocking []string
+	for _, k := range keys {
+		s := states[k]
+		switch {
+		case s.err != "":
+			blocking = append(blocking, fmt.Sprintf("%s (%s)", k, s.err))
+		case len(s.blocking) > 0:
+			blocking = append(blocking, fmt.Sprintf("%s (%s)", k, strings.Join(s.blocking, ", ")))
+		}
+	}
+	return fmt.Errorf("timed out after %s waiting for %d contexts: %s", timeout, len(blocking), strings.Join(blocking, "; "))
+}
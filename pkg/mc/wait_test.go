@@ -0,0 +1,166 @@
+package mc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"sync"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jonnylangefeld/kubectl-mc/pkg/mc/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func deployment(name string, available string) string {
+	return fmt.Sprintf(`{"kind":"Deployment","metadata":{"name":%q},"status":{"conditions":[{"type":"Progressing","status":"True"},{"type":"Available","status":%q}]}}`, name, available)
+}
+
+func TestMC_Wait(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	list := mocks.NewMockCmd(ctrl)
+	kind := mocks.NewMockCmd(ctrl)
+	kind1 := mocks.NewMockCmd(ctrl)
+
+	list.EXPECT().Output().Return([]byte("kind-kind\nkind-kind1\n"), nil)
+	// kind-kind is only polled once, as it meets the condition right away
+	kind.EXPECT().Output().Return([]byte(deployment("app", "True")), nil)
+	kind1.EXPECT().Output().Return([]byte(deployment("app", "False")), nil)
+	kind1.EXPECT().Output().Return([]byte(deployment("app", "True")), nil)
+
+	mc := New("")
+	mc.getListContextsCmd = func() Cmd {
+		return list
+	}
+	var mutex sync.Mutex
+	var gotArgs []string
+	mc.getKubectlCmd = func(ctx context.Context, args []string, c string, namespace string) Cmd {
+		mutex.Lock()
+		defer mutex.Unlock()
+		gotArgs = args
+		if c == kubeContext {
+			return kind
+		}
+		return kind1
+	}
+	b := bytes.NewBuffer([]byte(``))
+	mc.Cmd.SetOut(b)
+	mc.Cmd.SetArgs([]string{"wait", "-r", "kind", "--for", "condition=available", "--interval", "1ms", "--", "deploy/app"})
+	assert.NoError(t, mc.Cmd.Execute())
+	assert.Equal(t, []string{"get", "deploy/app", "-o", "json"}, gotArgs)
+	assert.Contains(t, b.String(), "CONTEXT      Deployment/app   STATUS\nkind-kind    True             done\nkind-kind1   False            waiting\n")
+	assert.Contains(t, b.String(), "CONTEXT      Deployment/app   STATUS\nkind-kind    True             done\nkind-kind1   True             done\n\nall objects in 2 contexts met the condition after 0s\n")
+}
+
+func TestMC_WaitTimeout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	list := mocks.NewMockCmd(ctrl)
+	m := mocks.NewMockCmd(ctrl)
+
+	refused := mocks.NewMockCmd(ctrl)
+	list.EXPECT().Output().Return([]byte("kind-kind\nkind-kind1\nkind-kind2\n"), nil)
+	m.EXPECT().Output().Return([]byte(`{"kind":"List","items":[{"kind":"Pod","metadata":{"name":"a"}}]}`), nil).MinTimes(1)
+	refused.EXPECT().Output().Return(nil, &exec.ExitError{Stderr: []byte("Unable to connect to the server: dial tcp: connection refused")}).MinTimes(1)
+
+	mc := New("")
+	mc.getListContextsCmd = func() Cmd {
+		return list
+	}
+	mc.getKubectlCmd = func(ctx context.Context, args []string, c string, namespace string) Cmd {
+		switch c {
+		case kubeContext:
+			return m
+		case "kind-kind1":
+			return refused
+		}
+		// an unreachable cluster only returns once the timeout kills kubectl
+		unreachable := mocks.NewMockCmd(ctrl)
+		unreachable.EXPECT().Output().DoAndReturn(func() ([]byte, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+		return unreachable
+	}
+	b := bytes.NewBuffer([]byte(``))
+	mc.Cmd.SetOut(b)
+	mc.Cmd.SetErr(ioutil.Discard)
+	mc.Cmd.SetArgs([]string{"wait", "--for", "delete", "--timeout", "50ms", "--interval", "1ms", "--", "pods", "-l", "app=x"})
+	err := mc.Cmd.Execute()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "timed out after 50ms waiting for 3 contexts: ")
+	assert.Contains(t, err.Error(), "(Pod/a)")
+	assert.Contains(t, err.Error(), "(Unable to connect to the server: dial tcp: connection refused)")
+	assert.Contains(t, err.Error(), "kind-kind2 (no response within the timeout)")
+	assert.Contains(t, b.String(), "error: Unable to connect")
+	assert.NotContains(t, b.String(), clearScreen)
+}
+
+func TestParseWaitFor(t *testing.T) {
+	tests := map[string]struct {
+		want    waitCondition
+		wantErr error
+	}{
+		"delete":                 {want: waitCondition{delete: true}},
+		"condition=Ready":        {want: waitCondition{condition: "Ready", value: "true"}},
+		"condition=Ready=False":  {want: waitCondition{condition: "Ready", value: "False"}},
+		"condition=":             {wantErr: errInvalidWaitFor},
+		"jsonpath={.status}=foo": {wantErr: errInvalidWaitFor},
+	}
+	for s, test := range tests {
+		t.Run(s, func(t *testing.T) {
+			got, err := parseWaitFor(s)
+			assert.Equal(t, test.wantErr, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestWaitCondition_Evaluate(t *testing.T) {
+	available := waitCondition{condition: "Available", value: "true"}
+	deleted := waitCondition{delete: true}
+	notFound := fmt.Errorf(`Error from server (NotFound): deployments.apps "app" not found`)
+
+	tests := map[string]struct {
+		cond     waitCondition
+		result   Result
+		want     waitState
+		wantDone bool
+	}{
+		"met": {
+			cond:     available,
+			result:   Result{Stdout: []byte(deployment("app", "True"))},
+			want:     waitState{cells: map[string]string{"Deployment/app": "True"}},
+			wantDone: true,
+		},
+		"not met": {
+			cond:   available,
+			result: Result{Stdout: []byte(`{"items":[` + deployment("a", "False") + `,{"kind":"Deployment","metadata":{"name":"b"}}]}`)},
+			want:   waitState{cells: map[string]string{"Deployment/a": "False", "Deployment/b": "<none>"}, blocking: []string{"Deployment/a", "Deployment/b"}},
+		},
+		"no items": {
+			cond:   available,
+			result: Result{Stdout: []byte(`{"items":[]}`)},
+			want:   waitState{cells: map[string]string{}, err: "no matching resources found"},
+		},
+		"deleted": {
+			cond:     deleted,
+			result:   Result{Err: notFound},
+			want:     waitState{cells: map[string]string{}},
+			wantDone: true,
+		},
+		"not found": {
+			cond:   available,
+			result: Result{Err: notFound},
+			want:   waitState{cells: map[string]string{}, err: "not found"},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := test.cond.evaluate(test.result)
+			assert.Equal(t, test.want, got)
+			assert.Equal(t, test.wantDone, got.done())
+		})
+	}
+}
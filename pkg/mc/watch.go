@@ -0,0 +1,125 @@
+package mc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	clearScreen    = "\x1b[H\x1b[2J"
+	highlightStart = "\x1b[7m"
+	highlightEnd   = "\x1b[0m"
+)
+
+var errWatchUnsupported = fmt.Errorf("--watch can't be combined with csv, tsv or prometheus output, --output-dir, --junit, --metrics-file or tracing")
+
+// watchEvent is emitted for every context and namespace whose output changed in structured watch mode
+type watchEvent struct {
+	Time      time.Time       `json:"time"`
+	Context   string          `json:"context"`
+	Namespace string          `json:"namespace,omitempty"`
+	Output    json.RawMessage `json:"output,omitempty"`
+	Error     string          `json:"error,omitempty"`
+}
+
+// watch executes args against the contexts every interval until ctx is done. The text output is redrawn in place
+// with the lines highlighted that changed since the previous iteration. Structured output emits a JSONL event for
+// every context and namespace whose output changed
+func (mc *MC) watch(ctx context.Context, contexts []string, args []string) error {
+	r := mc.runner(nil)
+	previous := map[string]string{}
+	for {
+		results, err := r.RunContexts(ctx, contexts, args)
+		// every iteration executes the command again, so every iteration is audited
+		if mc.AuditLog != "" {
+			if err := mc.audit(mc.argv, contexts, results); err != nil {
+				return fmt.Errorf("couldn't write audit log: %v", err)
+			}
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if mc.Output == "" {
+			mc.redraw(args, results, previous)
+		} else if err := mc.writeWatchEvents(results, previous); err != nil {
+			return err
+		}
+		for _, res := range results {
+			previous[res.key()] = watchOutput(res)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(mc.Watch):
+		}
+	}
+}
+
+// redraw prints all results. With color the terminal is cleared first and the lines that differ from the previous
+// output of the same context and namespace are highlighted, otherwise every iteration is appended to the output
+func (mc *MC) redraw(args []string, results []Result, previous map[string]string) {
+	var b strings.Builder
+	if mc.color {
+		b.WriteString(clearScreen)
+	}
+	fmt.Fprintf(&b, "Every %s: kubectl %s\t%s\n", mc.Watch, strings.Join(args, " "), time.Now().Format(time.RFC1123))
+	for _, res := range results {
+		prev, ok := previous[res.key()]
+		b.WriteString(mc.formatText(res, highlightChanges(prev, watchOutput(res), ok && mc.color)))
+	}
+	fmt.Fprint(mc.Cmd.OutOrStdout(), b.String())
+}
+
+// writeWatchEvents writes a JSONL event for every result whose output differs from the previous one
+func (mc *MC) writeWatchEvents(results []Result, previous map[string]string) error {
+	enc := json.NewEncoder(mc.Cmd.OutOrStdout())
+	for _, res := range results {
+		if prev, ok := previous[res.key()]; ok && prev == watchOutput(res) {
+			continue
+		}
+		e := watchEvent{Time: time.Now(), Context: res.Context, Namespace: res.Namespace}
+		if res.Err != nil {
+			e.Error = res.Err.Error()
+		} else if json.Valid(res.Stdout) {
+			e.Output = res.Stdout
+		} else {
+			return errCouldntParseOutput
+		}
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// watchOutput returns the output of a result as printed, which is the error message for failed executions
+func watchOutput(r Result) string {
+	if r.Err != nil {
+		return r.Err.Error()
+	}
+	return string(r.Stdout)
+}
+
+// highlightChanges highlights every line of current that differs from the line at the same position in previous.
+// Nothing is highlighted if there is no previous output yet
+func highlightChanges(previous string, current string, hasPrevious bool) string {
+	if !hasPrevious {
+		return current
+	}
+	prev := strings.Split(previous, "\n")
+	lines := strings.Split(current, "\n")
+	for i, l := range lines {
+		if l != "" && (i >= len(prev) || prev[i] != l) {
+			lines[i] = highlightStart + l + highlightEnd
+		}
+	}
+	return strings.Join(lines, "\n")
+}
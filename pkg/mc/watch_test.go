@@ -0,0 +1,106 @@
+package mc
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jonnylangefeld/kubectl-mc/pkg/mc/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMC_Watch(t *testing.T) {
+	tests := map[string]struct {
+		args    []string
+		outputs []string
+		want    []string
+		notWant []string
+		// wantLines is the amount of lines written, if set
+		wantLines int
+	}{
+		"text": {
+			args:    []string{"--watch", "1ms", "--", "get", "pods"},
+			outputs: []string{"NAME   READY\npod-a  0/1\n", "NAME   READY\npod-a  1/1\n"},
+			want: []string{
+				"Every 1ms: kubectl get pods\t",
+				"\nkind-kind\n---------\nNAME   READY\npod-a  0/1\n",
+				"\nkind-kind\n---------\nNAME   READY\npod-a  1/1\n",
+			},
+			// without color, every iteration is appended as plain text
+			notWant: []string{clearScreen, highlightStart},
+		},
+		"text with color": {
+			args:    []string{"--watch", "1ms", "--color", "always", "--", "get", "pods"},
+			outputs: []string{"NAME   READY\npod-a  0/1\n", "NAME   READY\npod-a  1/1\n"},
+			want: []string{
+				clearScreen + "Every 1ms: kubectl get pods\t",
+				highlightStart + "pod-a  1/1" + highlightEnd + "\n",
+			},
+		},
+		"json events": {
+			args:    []string{"--watch", "1ms", "-o", "json", "--", "get", "pods"},
+			outputs: []string{`{"items":[]}`, `{"items":[]}`, `{"items": [1]}`},
+			want:    []string{`"context":"kind-kind","output":{"items":[]}}`, `"context":"kind-kind","output":{"items":[1]}}`},
+			// the unchanged second iteration doesn't emit an event
+			wantLines: 2,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			list := mocks.NewMockCmd(ctrl)
+			m := mocks.NewMockCmd(ctrl)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			list.EXPECT().Output().Return([]byte("kind-kind\n"), nil)
+			for _, o := range test.outputs {
+				m.EXPECT().Output().Return([]byte(o), nil)
+			}
+			// the watch stops as soon as ctx is canceled, which happens during the last iteration
+			m.EXPECT().Output().DoAndReturn(func() ([]byte, error) {
+				cancel()
+				return nil, nil
+			})
+
+			mc := New("")
+			mc.getListContextsCmd = func() Cmd {
+				return list
+			}
+			mc.getKubectlCmd = func(ctx context.Context, args []string, c string, namespace string) Cmd {
+				return m
+			}
+			b := bytes.NewBuffer([]byte(``))
+			mc.Cmd.SetOut(b)
+			mc.Cmd.SetArgs(test.args)
+			assert.NoError(t, mc.Cmd.ExecuteContext(ctx))
+			for _, w := range test.want {
+				assert.Contains(t, b.String(), w)
+			}
+			for _, w := range test.notWant {
+				assert.NotContains(t, b.String(), w)
+			}
+			if test.wantLines > 0 {
+				assert.Equal(t, test.wantLines, strings.Count(b.String(), "\n"))
+			}
+		})
+	}
+}
+
+func TestMC_WatchUnsupported(t *testing.T) {
+	mc := New("")
+	mc.Cmd.SetOut(ioutil.Discard)
+	mc.Cmd.SetErr(ioutil.Discard)
+	mc.Cmd.SetArgs([]string{"--watch", "2s", "-o", "csv", "--", "get", "pods"})
+	assert.Equal(t, errWatchUnsupported, mc.Cmd.Execute())
+}
+
+func TestHighlightChanges(t *testing.T) {
+	assert.Equal(t, "a\nb\n", highlightChanges("", "a\nb\n", false))
+	assert.Equal(t, "a\nb\n", highlightChanges("a\nb\n", "a\nb\n", true))
+	assert.Equal(t, "a\n"+highlightStart+"c"+highlightEnd+"\n"+highlightStart+"d"+highlightEnd+"\n", highlightChanges("a\nb\n", "a\nc\nd\n", true))
+}